@@ -6,7 +6,79 @@ import (
 
 // -----------------------------------------------------------------------------
 
+// DefaultAlignment is the alignment that blocks returned by Alloc are expected to have.
+const DefaultAlignment = 16
+
+// -----------------------------------------------------------------------------
+
 type Allocator interface {
 	Alloc(size uintptr) unsafe.Pointer
 	Free(ptr unsafe.Pointer)
 }
+
+// ZeroAllocator is an optional interface for allocators that can return zeroed memory faster than Alloc followed by
+// ZeroMem, for example, by using calloc.
+type ZeroAllocator interface {
+	AllocZeroed(size uintptr) unsafe.Pointer
+}
+
+// Reallocator is an optional interface for allocators that can resize a block, possibly moving it. The contents are
+// preserved up to the smallest of the old and new sizes. On failure, nil is returned and the original block is left
+// untouched. The size must not be zero.
+type Reallocator interface {
+	Realloc(ptr unsafe.Pointer, size uintptr) unsafe.Pointer
+}
+
+// AlignedAllocator is an optional interface for allocators that can return blocks with an alignment greater than
+// DefaultAlignment. The alignment must be a power of two. Blocks are released with Free.
+type AlignedAllocator interface {
+	AllocAligned(size uintptr, alignment uintptr) unsafe.Pointer
+}
+
+// BulkReleaser is an optional interface for allocators whose Free does nothing because memory is reclaimed in bulk,
+// like arenas. When FreeIsNoop returns true, the generated code skips freeing the strings and native pointers an
+// object owns. Nested objects are still freed because they may have been created with a different allocator.
+type BulkReleaser interface {
+	FreeIsNoop() bool
+}
+
+// -----------------------------------------------------------------------------
+
+// AllocZeroed allocates a zeroed block, using the allocator's ZeroAllocator implementation if available.
+func AllocZeroed(a Allocator, size uintptr) unsafe.Pointer {
+	if za, ok := a.(ZeroAllocator); ok {
+		return za.AllocZeroed(size)
+	}
+
+	ptr := a.Alloc(size)
+	if ptr != nil {
+		ZeroMem(ptr, size)
+	}
+	return ptr
+}
+
+// AllocZeroedAligned allocates a zeroed block with the given alignment. If the alignment is greater than
+// DefaultAlignment, the allocator must implement AlignedAllocator or nil is returned.
+func AllocZeroedAligned(a Allocator, size uintptr, alignment uintptr) unsafe.Pointer {
+	if alignment <= DefaultAlignment {
+		return AllocZeroed(a, size)
+	}
+
+	aa, ok := a.(AlignedAllocator)
+	if !ok {
+		return nil
+	}
+	ptr := aa.AllocAligned(size, alignment)
+	if ptr != nil {
+		ZeroMem(ptr, size)
+	}
+	return ptr
+}
+
+// FreeIsNoop returns true if the allocator implements BulkReleaser and its Free does nothing.
+func FreeIsNoop(a Allocator) bool {
+	if br, ok := a.(BulkReleaser); ok {
+		return br.FreeIsNoop()
+	}
+	return false
+}
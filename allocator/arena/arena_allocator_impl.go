@@ -0,0 +1,211 @@
+package arena
+
+import (
+	"sync"
+	"unsafe"
+
+	"github.com/mxmauro/unmanagedgen/allocator"
+)
+
+// -----------------------------------------------------------------------------
+
+const (
+	DefaultChunkSize = 1024 * 1024
+
+	alignment = 16
+)
+
+// -----------------------------------------------------------------------------
+
+// ArenaAllocator bump-allocates memory from big chunks obtained from a backing allocator.
+//
+// Free is a no-op. Memory is reclaimed all at once with Reset, Release or by rewinding to a Checkpoint. Blocks larger
+// than half of the chunk size are allocated directly from the backing allocator but are still owned by the arena.
+type ArenaAllocator struct {
+	mtx       sync.Mutex
+	backing   allocator.Allocator
+	chunkSize uintptr
+	chunks    []chunk
+	spare     []chunk
+	large     []unsafe.Pointer
+	offset    uintptr
+	blocks    uint64
+	bytes     uint64
+	stats     allocator.StatsCounter
+}
+
+// Checkpoint marks a position inside an arena. Rewinding to it drops everything allocated afterwards.
+type Checkpoint struct {
+	chunks int
+	offset uintptr
+	large  int
+	blocks uint64
+	bytes  uint64
+}
+
+type chunk struct {
+	ptr  unsafe.Pointer
+	size uintptr
+}
+
+// -----------------------------------------------------------------------------
+
+// New creates a new arena allocator that gets chunks of the given size from the backing allocator. If chunkSize
+// is zero, DefaultChunkSize is used.
+func New(backing allocator.Allocator, chunkSize uintptr) *ArenaAllocator {
+	if chunkSize == 0 {
+		chunkSize = DefaultChunkSize
+	}
+	chunkSize = (chunkSize + alignment - 1) &^ (alignment - 1)
+
+	return &ArenaAllocator{
+		backing:   backing,
+		chunkSize: chunkSize,
+		chunks:    make([]chunk, 0),
+		spare:     make([]chunk, 0),
+		large:     make([]unsafe.Pointer, 0),
+	}
+}
+
+func (a *ArenaAllocator) Alloc(size uintptr) unsafe.Pointer {
+	size, overflow := allocator.AddUintptr(size, alignment-1)
+	if overflow {
+		return nil
+	}
+	size &^= alignment - 1
+	if size == 0 {
+		size = alignment
+	}
+
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	if size > a.chunkSize/2 {
+		ptr := a.backing.Alloc(size)
+		if ptr != nil {
+			a.large = append(a.large, ptr)
+			a.recordAlloc(size)
+		}
+		return ptr
+	}
+
+	if len(a.chunks) == 0 || size > a.chunks[len(a.chunks)-1].size-a.offset {
+		if !a.nextChunk() {
+			return nil
+		}
+	}
+
+	ptr := unsafe.Add(a.chunks[len(a.chunks)-1].ptr, a.offset)
+	a.offset += size
+	a.recordAlloc(size)
+	return ptr
+}
+
+// Free does nothing. Memory owned by the arena is reclaimed in bulk.
+func (a *ArenaAllocator) Free(_ unsafe.Pointer) {
+}
+
+// FreeIsNoop implements allocator.BulkReleaser.
+func (a *ArenaAllocator) FreeIsNoop() bool {
+	return true
+}
+
+// Checkpoint returns the current position of the arena.
+func (a *ArenaAllocator) Checkpoint() Checkpoint {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	return Checkpoint{
+		chunks: len(a.chunks),
+		offset: a.offset,
+		large:  len(a.large),
+		blocks: a.blocks,
+		bytes:  a.bytes,
+	}
+}
+
+// Rewind drops every block allocated after the given checkpoint was taken. Checkpoints taken after cp become invalid.
+func (a *ArenaAllocator) Rewind(cp Checkpoint) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	if cp.chunks > len(a.chunks) || cp.large > len(a.large) || (cp.chunks == len(a.chunks) && cp.offset > a.offset) {
+		panic("ArenaAllocator: invalid checkpoint")
+	}
+
+	for idx := cp.large; idx < len(a.large); idx++ {
+		a.backing.Free(a.large[idx])
+		a.large[idx] = nil
+	}
+	a.large = a.large[:cp.large]
+
+	// Keep dropped chunks around for reuse
+	for idx := len(a.chunks) - 1; idx >= cp.chunks; idx-- {
+		a.spare = append(a.spare, a.chunks[idx])
+		a.chunks[idx] = chunk{}
+	}
+	a.chunks = a.chunks[:cp.chunks]
+	a.offset = cp.offset
+
+	a.stats.RecordBulkFree(a.blocks-cp.blocks, a.bytes-cp.bytes)
+	a.blocks = cp.blocks
+	a.bytes = cp.bytes
+}
+
+// Scope runs fn and drops everything allocated by the arena while it was running.
+func (a *ArenaAllocator) Scope(fn func()) {
+	cp := a.Checkpoint()
+	defer a.Rewind(cp)
+
+	fn()
+}
+
+// Reset drops everything allocated by the arena but keeps its chunks for reuse.
+func (a *ArenaAllocator) Reset() {
+	a.Rewind(Checkpoint{})
+}
+
+// Release drops everything allocated by the arena and gives all its memory back to the backing allocator.
+func (a *ArenaAllocator) Release() {
+	a.Reset()
+
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	for idx := range a.spare {
+		a.backing.Free(a.spare[idx].ptr)
+		a.spare[idx] = chunk{}
+	}
+	a.spare = a.spare[:0]
+}
+
+// Stats returns the usage statistics. Blocks dropped in bulk are accounted as freed.
+func (a *ArenaAllocator) Stats() allocator.Stats {
+	return a.stats.Snapshot()
+}
+
+func (a *ArenaAllocator) recordAlloc(size uintptr) {
+	a.blocks += 1
+	a.bytes += uint64(size)
+	a.stats.RecordAlloc(size)
+}
+
+func (a *ArenaAllocator) nextChunk() bool {
+	var c chunk
+
+	if len(a.spare) > 0 {
+		c = a.spare[len(a.spare)-1]
+		a.spare[len(a.spare)-1] = chunk{}
+		a.spare = a.spare[:len(a.spare)-1]
+	} else {
+		c.ptr = a.backing.Alloc(a.chunkSize)
+		if c.ptr == nil {
+			return false
+		}
+		c.size = a.chunkSize
+	}
+
+	a.chunks = append(a.chunks, c)
+	a.offset = 0
+	return true
+}
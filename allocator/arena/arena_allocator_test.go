@@ -0,0 +1,70 @@
+package arena_test
+
+import (
+	"testing"
+
+	"github.com/mxmauro/unmanagedgen/allocator/arena"
+	"github.com/mxmauro/unmanagedgen/allocator/internal/testalloc"
+)
+
+// -----------------------------------------------------------------------------
+
+func TestArenaAllocator(t *testing.T) {
+	backing := testalloc.NewHeap()
+	alloc := arena.New(backing, 4096)
+
+	for idx := 0; idx < 100; idx++ {
+		ptr := alloc.Alloc(uintptr(1 + idx))
+		if ptr == nil || uintptr(ptr)&15 != 0 {
+			t.Fatalf("invalid block returned")
+		}
+		alloc.Free(ptr)
+	}
+	chunks := backing.Live()
+	if chunks < 2 {
+		t.Fatalf("expected several chunks, got %v", chunks)
+	}
+
+	// Nested scopes must give back large blocks and reuse chunks
+	alloc.Scope(func() {
+		_ = alloc.Alloc(10000)
+		alloc.Scope(func() {
+			for idx := 0; idx < 100; idx++ {
+				_ = alloc.Alloc(512)
+			}
+		})
+	})
+	afterScopes := backing.Live()
+
+	alloc.Reset()
+	for idx := 0; idx < 100; idx++ {
+		_ = alloc.Alloc(uintptr(1 + idx))
+	}
+	if backing.Live() != afterScopes {
+		t.Fatalf("chunks were not reused after reset [before=%v, after=%v]", afterScopes, backing.Live())
+	}
+
+	alloc.Release()
+	if backing.Live() != 0 {
+		t.Fatalf("%v blocks were not released", backing.Live())
+	}
+	if s := alloc.Stats(); s.BytesInUse != 0 || s.Allocs != s.Frees || s.HighWaterMark == 0 {
+		t.Fatalf("unexpected stats after release: %+v", s)
+	}
+}
+
+func TestArenaAllocatorInvalidCheckpoint(t *testing.T) {
+	alloc := arena.New(testalloc.NewHeap(), 0)
+
+	outer := alloc.Checkpoint()
+	_ = alloc.Alloc(64)
+	inner := alloc.Checkpoint()
+	alloc.Rewind(outer)
+
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("rewinding to a dropped checkpoint must panic")
+		}
+	}()
+	alloc.Rewind(inner)
+}
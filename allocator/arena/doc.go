@@ -0,0 +1,3 @@
+// Package arena provides a region allocator that bump-allocates from big chunks taken from a backing allocator and
+// releases everything at once.
+package arena
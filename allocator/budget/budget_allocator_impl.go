@@ -0,0 +1,186 @@
+package budget
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"unsafe"
+
+	"github.com/mxmauro/unmanagedgen/allocator"
+)
+
+// -----------------------------------------------------------------------------
+
+// Every block is prefixed with its size so Free can give it back to the budget. 16 bytes keep the user data
+// aligned.
+const headerSize = 16
+
+// ErrBudgetExceeded is the error used when an allocation does not fit in the budget and the failure mode is
+// FailPanic.
+var ErrBudgetExceeded = errors.New("memory budget exceeded")
+
+// -----------------------------------------------------------------------------
+
+// FailureMode defines what Alloc does when the request does not fit in the budget.
+type FailureMode int
+
+const (
+	// FailReturnNil makes Alloc return nil, like an out-of-memory condition.
+	FailReturnNil FailureMode = iota
+
+	// FailPanic makes Alloc panic with an error wrapping ErrBudgetExceeded.
+	FailPanic
+)
+
+// Options configures a BudgetAllocator.
+type Options struct {
+	// Limit is the maximum number of bytes that can be allocated at once. Zero means no limit.
+	Limit uint64
+
+	// SoftLimit, if not zero, is the usage above which OnPressure is invoked.
+	SoftLimit uint64
+
+	// OnPressure is called when usage crosses SoftLimit upward. It is called again only after usage drops back
+	// below the soft limit.
+	OnPressure func(inUse uint64)
+
+	// OnLimitExceeded is called when an allocation does not fit in the budget. It may free memory, for example by
+	// evicting caches, or raise the limit, and return true to retry the allocation. Returning false applies the
+	// FailureMode. The FailureMode is also applied if it returns true without the usage dropping or the limit
+	// growing, so a callback that has nothing left to free cannot make Alloc retry forever.
+	OnLimitExceeded func(requested uintptr, inUse uint64) bool
+
+	// FailureMode defines how a failed allocation is reported.
+	FailureMode FailureMode
+}
+
+// BudgetAllocator enforces a byte budget over another allocator.
+type BudgetAllocator struct {
+	base          allocator.Allocator
+	opts          Options
+	limit         atomic.Uint64
+	inUse         atomic.Uint64
+	underPressure atomic.Bool
+	stats         allocator.StatsCounter
+}
+
+// -----------------------------------------------------------------------------
+
+// New creates a new budget allocator on top of the given one.
+func New(base allocator.Allocator, opts Options) *BudgetAllocator {
+	a := BudgetAllocator{
+		base: base,
+		opts: opts,
+	}
+	a.limit.Store(opts.Limit)
+	return &a
+}
+
+func (a *BudgetAllocator) Alloc(size uintptr) unsafe.Pointer {
+	total, overflow := allocator.AddUintptr(size, headerSize)
+	if overflow {
+		return a.fail(size)
+	}
+
+	for !a.reserve(uint64(total)) {
+		if a.opts.OnLimitExceeded == nil {
+			return a.fail(size)
+		}
+		inUse := a.inUse.Load()
+		limit := a.limit.Load()
+		if !a.opts.OnLimitExceeded(size, inUse) {
+			return a.fail(size)
+		}
+		// Retry only if the callback made some room. A zero limit means there is none anymore.
+		newLimit := a.limit.Load()
+		if a.inUse.Load() >= inUse && newLimit != 0 && newLimit <= limit {
+			return a.fail(size)
+		}
+	}
+
+	ptr := a.base.Alloc(total)
+	if ptr == nil {
+		a.release(uint64(total))
+		return nil
+	}
+	*((*uintptr)(ptr)) = total
+	a.stats.RecordAlloc(size)
+
+	a.checkPressure()
+	return unsafe.Add(ptr, headerSize)
+}
+
+func (a *BudgetAllocator) Free(ptr unsafe.Pointer) {
+	if ptr == nil {
+		return
+	}
+
+	ptr = unsafe.Add(ptr, -headerSize)
+	total := *((*uintptr)(ptr))
+	a.base.Free(ptr)
+
+	a.stats.RecordFree(total - headerSize)
+	a.release(uint64(total))
+}
+
+// InUse returns the number of bytes currently charged to the budget, including per-block overhead.
+func (a *BudgetAllocator) InUse() uint64 {
+	return a.inUse.Load()
+}
+
+// Limit returns the current hard limit.
+func (a *BudgetAllocator) Limit() uint64 {
+	return a.limit.Load()
+}
+
+// SetLimit changes the hard limit. Memory already allocated above the new limit is not affected.
+func (a *BudgetAllocator) SetLimit(limit uint64) {
+	a.limit.Store(limit)
+}
+
+// Stats returns the usage statistics.
+func (a *BudgetAllocator) Stats() allocator.Stats {
+	return a.stats.Snapshot()
+}
+
+func (a *BudgetAllocator) reserve(size uint64) bool {
+	for {
+		inUse := a.inUse.Load()
+		newInUse := inUse + size
+		if newInUse < inUse {
+			return false
+		}
+		limit := a.limit.Load()
+		if limit > 0 && newInUse > limit {
+			return false
+		}
+		if a.inUse.CompareAndSwap(inUse, newInUse) {
+			return true
+		}
+	}
+}
+
+func (a *BudgetAllocator) release(size uint64) {
+	inUse := a.inUse.Add(-size)
+	if a.opts.SoftLimit > 0 && inUse < a.opts.SoftLimit {
+		a.underPressure.Store(false)
+	}
+}
+
+func (a *BudgetAllocator) checkPressure() {
+	if a.opts.SoftLimit == 0 || a.opts.OnPressure == nil {
+		return
+	}
+	inUse := a.inUse.Load()
+	if inUse > a.opts.SoftLimit && a.underPressure.CompareAndSwap(false, true) {
+		a.opts.OnPressure(inUse)
+	}
+}
+
+func (a *BudgetAllocator) fail(size uintptr) unsafe.Pointer {
+	if a.opts.FailureMode == FailPanic {
+		panic(fmt.Errorf("%w [requested=%v, in-use=%v, limit=%v]", ErrBudgetExceeded, size, a.inUse.Load(),
+			a.limit.Load()))
+	}
+	return nil
+}
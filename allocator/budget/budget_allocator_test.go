@@ -0,0 +1,105 @@
+package budget_test
+
+import (
+	"errors"
+	"testing"
+	"unsafe"
+
+	"github.com/mxmauro/unmanagedgen/allocator/budget"
+	"github.com/mxmauro/unmanagedgen/allocator/internal/testalloc"
+)
+
+// -----------------------------------------------------------------------------
+
+func TestBudgetAllocator(t *testing.T) {
+	var cached unsafe.Pointer
+	var alloc *budget.BudgetAllocator
+
+	pressureCalls := 0
+	alloc = budget.New(testalloc.NewHeap(), budget.Options{
+		Limit:     1024,
+		SoftLimit: 512,
+		OnPressure: func(_ uint64) {
+			pressureCalls++
+		},
+		OnLimitExceeded: func(_ uintptr, _ uint64) bool {
+			if cached == nil {
+				return false
+			}
+			// Evict the cache and retry
+			alloc.Free(cached)
+			cached = nil
+			return true
+		},
+	})
+
+	cached = alloc.Alloc(600)
+	if cached == nil || pressureCalls != 1 {
+		t.Fatalf("first allocation failed or pressure not reported")
+	}
+	ptr := alloc.Alloc(600)
+	if ptr == nil || cached != nil {
+		t.Fatalf("cache was not evicted to make room")
+	}
+	if alloc.Alloc(600) != nil {
+		t.Fatalf("allocation above the limit succeeded")
+	}
+
+	alloc.Free(ptr)
+	if alloc.InUse() != 0 {
+		t.Fatalf("budget not released [in-use=%v]", alloc.InUse())
+	}
+	alloc.Free(alloc.Alloc(600))
+	if pressureCalls != 3 {
+		t.Fatalf("pressure was not reported after dropping below the soft limit [calls=%v]", pressureCalls)
+	}
+}
+
+func TestBudgetAllocatorNoProgress(t *testing.T) {
+	calls := 0
+	alloc := budget.New(testalloc.NewHeap(), budget.Options{
+		Limit: 100,
+		OnLimitExceeded: func(_ uintptr, _ uint64) bool {
+			// Nothing gets freed, so the allocation must fail instead of being retried forever
+			calls++
+			return true
+		},
+	})
+
+	if alloc.Alloc(200) != nil || calls != 1 {
+		t.Fatalf("allocation retried without progress [calls=%v]", calls)
+	}
+}
+
+func TestBudgetAllocatorRaiseLimit(t *testing.T) {
+	var alloc *budget.BudgetAllocator
+
+	alloc = budget.New(testalloc.NewHeap(), budget.Options{
+		Limit: 100,
+		OnLimitExceeded: func(_ uintptr, _ uint64) bool {
+			alloc.SetLimit(alloc.Limit() * 2)
+			return true
+		},
+	})
+
+	ptr := alloc.Alloc(200)
+	if ptr == nil || alloc.Limit() != 400 {
+		t.Fatalf("allocation not retried after raising the limit [limit=%v]", alloc.Limit())
+	}
+	alloc.Free(ptr)
+}
+
+func TestBudgetAllocatorPanic(t *testing.T) {
+	alloc := budget.New(testalloc.NewHeap(), budget.Options{
+		Limit:       100,
+		FailureMode: budget.FailPanic,
+	})
+
+	defer func() {
+		err, ok := recover().(error)
+		if !ok || !errors.Is(err, budget.ErrBudgetExceeded) {
+			t.Fatalf("expected an ErrBudgetExceeded panic [err=%v]", err)
+		}
+	}()
+	alloc.Alloc(200)
+}
@@ -0,0 +1,2 @@
+// Package budget provides an allocator wrapper that caps the amount of memory a subsystem can allocate.
+package budget
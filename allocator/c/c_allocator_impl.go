@@ -2,27 +2,171 @@
 
 package c
 
-// #include <memory.h>
-// #include <stdlib.h>
+/*
+#include <memory.h>
+#include <stdlib.h>
+
+static void *unmanagedgen_aligned_alloc(size_t size, size_t alignment) {
+#if defined(_WIN32)
+	// Memory returned by _aligned_malloc cannot be released with free
+	(void)size;
+	(void)alignment;
+	return NULL;
+#else
+	void *ptr = NULL;
+	if (alignment < sizeof(void *)) {
+		alignment = sizeof(void *);
+	}
+	if (posix_memalign(&ptr, alignment, size) != 0) {
+		return NULL;
+	}
+	return ptr;
+#endif
+}
+*/
 import "C"
 import (
 	"unsafe"
+
+	"github.com/mxmauro/unmanagedgen/allocator"
 )
 
 // -----------------------------------------------------------------------------
 
+// When statistics are enabled, every block is prefixed with a header. 16 bytes keep the user data aligned.
+const statsHeaderSize = 16
+
+// -----------------------------------------------------------------------------
+
 type CAllocator struct {
+	stats *allocator.StatsCounter
+}
+
+type statsHeader struct {
+	size   uintptr // user size
+	offset uintptr // distance from the start of the C block to the user data
 }
 
+// -----------------------------------------------------------------------------
+
 func New() *CAllocator {
 	return &CAllocator{}
 }
 
+// NewWithStats creates a CAllocator that keeps usage statistics. Each block is prefixed with a small header that
+// holds its size.
+func NewWithStats() *CAllocator {
+	return &CAllocator{
+		stats: &allocator.StatsCounter{},
+	}
+}
+
 func (c *CAllocator) Alloc(size uintptr) unsafe.Pointer {
-	ptr := C.malloc(C.size_t(size))
-	return unsafe.Pointer(ptr)
+	if c.stats == nil {
+		ptr := C.malloc(C.size_t(size))
+		return unsafe.Pointer(ptr)
+	}
+
+	total, overflow := allocator.AddUintptr(size, statsHeaderSize)
+	if overflow {
+		return nil
+	}
+	return c.withStatsHeader(C.malloc(C.size_t(total)), size, statsHeaderSize)
+}
+
+// AllocZeroed allocates a zeroed block using calloc.
+func (c *CAllocator) AllocZeroed(size uintptr) unsafe.Pointer {
+	if c.stats == nil {
+		ptr := C.calloc(1, C.size_t(size))
+		return unsafe.Pointer(ptr)
+	}
+
+	total, overflow := allocator.AddUintptr(size, statsHeaderSize)
+	if overflow {
+		return nil
+	}
+	return c.withStatsHeader(C.calloc(1, C.size_t(total)), size, statsHeaderSize)
+}
+
+// Realloc resizes a block using realloc.
+func (c *CAllocator) Realloc(ptr unsafe.Pointer, size uintptr) unsafe.Pointer {
+	if c.stats == nil {
+		newPtr := C.realloc(ptr, C.size_t(size))
+		return unsafe.Pointer(newPtr)
+	}
+	if ptr == nil {
+		return c.Alloc(size)
+	}
+
+	hdr := getStatsHeader(ptr)
+	if hdr.offset != statsHeaderSize {
+		// Over-aligned blocks cannot be moved by realloc without losing their alignment
+		return nil
+	}
+	oldSize := hdr.size
+
+	total, overflow := allocator.AddUintptr(size, statsHeaderSize)
+	if overflow {
+		return nil
+	}
+	newPtr := C.realloc(unsafe.Add(ptr, -statsHeaderSize), C.size_t(total))
+	if newPtr == nil {
+		return nil
+	}
+	c.stats.RecordFree(oldSize)
+	return c.withStatsHeader(newPtr, size, statsHeaderSize)
+}
+
+// AllocAligned allocates a block with the given alignment using posix_memalign. It is not supported on Windows,
+// where nil is always returned.
+func (c *CAllocator) AllocAligned(size uintptr, alignment uintptr) unsafe.Pointer {
+	if c.stats == nil {
+		ptr := C.unmanagedgen_aligned_alloc(C.size_t(size), C.size_t(alignment))
+		return unsafe.Pointer(ptr)
+	}
+
+	offset := uintptr(statsHeaderSize)
+	if alignment > offset {
+		offset = alignment
+	}
+	total, overflow := allocator.AddUintptr(size, offset)
+	if overflow {
+		return nil
+	}
+	return c.withStatsHeader(C.unmanagedgen_aligned_alloc(C.size_t(total), C.size_t(alignment)), size, offset)
 }
 
 func (c *CAllocator) Free(ptr unsafe.Pointer) {
-	C.free(ptr)
+	if c.stats == nil || ptr == nil {
+		C.free(ptr)
+		return
+	}
+
+	hdr := getStatsHeader(ptr)
+	c.stats.RecordFree(hdr.size)
+	C.free(unsafe.Add(ptr, -int(hdr.offset)))
+}
+
+// Stats returns the usage statistics. They are all zero unless the allocator was created with NewWithStats.
+func (c *CAllocator) Stats() allocator.Stats {
+	if c.stats == nil {
+		return allocator.Stats{}
+	}
+	return c.stats.Snapshot()
+}
+
+func (c *CAllocator) withStatsHeader(ptr unsafe.Pointer, size uintptr, offset uintptr) unsafe.Pointer {
+	if ptr == nil {
+		return nil
+	}
+	ptr = unsafe.Add(ptr, offset)
+	hdr := getStatsHeader(ptr)
+	hdr.size = size
+	hdr.offset = offset
+	c.stats.RecordAlloc(size)
+	return ptr
+}
+
+func getStatsHeader(ptr unsafe.Pointer) *statsHeader {
+	return (*statsHeader)(unsafe.Add(ptr, -statsHeaderSize))
 }
@@ -0,0 +1,65 @@
+//go:build cgo
+
+package c_test
+
+import (
+	"testing"
+	"unsafe"
+
+	"github.com/mxmauro/unmanagedgen/allocator"
+	"github.com/mxmauro/unmanagedgen/allocator/c"
+)
+
+// -----------------------------------------------------------------------------
+
+func TestCAllocatorExtensions(t *testing.T) {
+	for _, alloc := range []*c.CAllocator{c.New(), c.NewWithStats()} {
+		ptr := alloc.AllocZeroed(64)
+		buf := unsafe.Slice((*byte)(ptr), 64)
+		for idx := range buf {
+			if buf[idx] != 0 {
+				t.Fatalf("AllocZeroed returned non-zeroed memory")
+			}
+			buf[idx] = byte(idx)
+		}
+
+		ptr = alloc.Realloc(ptr, 4096)
+		buf = unsafe.Slice((*byte)(ptr), 4096)
+		for idx := 0; idx < 64; idx++ {
+			if buf[idx] != byte(idx) {
+				t.Fatalf("Realloc did not preserve the contents")
+			}
+		}
+		alloc.Free(ptr)
+
+		ptr = alloc.AllocAligned(100, 256)
+		if ptr != nil {
+			if uintptr(ptr)%256 != 0 {
+				t.Fatalf("AllocAligned returned a misaligned block")
+			}
+			alloc.Free(ptr)
+		}
+
+		if alloc.Stats().BytesInUse != 0 {
+			t.Fatalf("Usage is not zero! [%v]", alloc.Stats().BytesInUse)
+		}
+	}
+}
+
+func TestAllocZeroedAligned(t *testing.T) {
+	alloc := c.NewWithStats()
+
+	ptr := allocator.AllocZeroedAligned(alloc, 32, 8)
+	if ptr == nil || *(*uint64)(ptr) != 0 {
+		t.Fatalf("unexpected block")
+	}
+	alloc.Free(ptr)
+
+	ptr = allocator.AllocZeroedAligned(alloc, 32, 64)
+	if ptr != nil {
+		if uintptr(ptr)%64 != 0 {
+			t.Fatalf("AllocZeroedAligned returned a misaligned block")
+		}
+		alloc.Free(ptr)
+	}
+}
@@ -6,14 +6,26 @@ package c
 // #include <stdlib.h>
 import "C"
 import (
-	"sync/atomic"
+	"fmt"
+	"runtime"
+	"sync"
 	"unsafe"
+
+	"github.com/mxmauro/unmanagedgen/allocator"
 )
 
 // -----------------------------------------------------------------------------
 
 const sizeOfUintptr = 32 << uintptr(^uintptr(0)>>63)
 const guardSize = 16
+const defaultStackDepth = 16
+
+// The block state is stored in the last word of the header, just before the user data
+const stateOffset = unsafe.Sizeof(uintptr(0))
+const (
+	blockStateAllocated = uintptr(0x5AFEB10C)
+	blockStateFreed     = uintptr(0xDEADB10C)
+)
 
 var guard [guardSize]byte
 
@@ -26,52 +38,185 @@ func init() {
 }
 
 type DebugCAllocator struct {
-	usage int64
+	stats allocator.StatsCounter
+	opts  DebugOptions
+
+	liveMtx sync.Mutex
+	live    map[unsafe.Pointer]liveBlock
+
+	quarantineMtx sync.Mutex
+	quarantine    quarantineQueue
+}
+
+// DebugOptions configures the optional checks done by a DebugCAllocator.
+type DebugOptions struct {
+	// TrackAllocations keeps a table of live blocks along with the stack that allocated each one. It is required by
+	// Leaks and CheckAll. Free also uses it to reject double frees and foreign pointers without reading their memory.
+	TrackAllocations bool
+
+	// StackDepth is the maximum number of frames recorded for each allocation. Defaults to 16.
+	StackDepth int
+
+	// QuarantineSize enables use-after-free detection when greater than zero. Freed blocks are filled with a poison
+	// pattern and kept in a FIFO of up to QuarantineSize blocks. When a block leaves the quarantine, the poison is
+	// verified. Enabling the quarantine also enables allocation tracking.
+	QuarantineSize int
+
+	// QuarantineBytes, if not zero, also limits the amount of memory held in the quarantine.
+	QuarantineBytes uintptr
+}
+
+type liveBlock struct {
+	size  uintptr
+	stack []uintptr
 }
 
 func NewWithDebug() *DebugCAllocator {
-	return &DebugCAllocator{}
+	return NewWithDebugOptions(DebugOptions{})
+}
+
+// NewWithDebugOptions creates a new DebugCAllocator with the given options.
+func NewWithDebugOptions(opts DebugOptions) *DebugCAllocator {
+	if opts.StackDepth <= 0 {
+		opts.StackDepth = defaultStackDepth
+	}
+	if opts.QuarantineSize > 0 {
+		opts.TrackAllocations = true
+	}
+
+	c := DebugCAllocator{
+		opts: opts,
+	}
+	if opts.TrackAllocations {
+		c.live = make(map[unsafe.Pointer]liveBlock)
+	}
+	if opts.QuarantineSize > 0 {
+		c.quarantine.blocks = make([]quarantinedBlock, opts.QuarantineSize)
+	}
+	return &c
 }
 
 func (c *DebugCAllocator) Alloc(size uintptr) unsafe.Pointer {
 	ptr := C.malloc(C.size_t(size) + sizeOfUintptr + guardSize*2)
+	if ptr == nil {
+		return nil
+	}
 
 	C.memcpy(ptr, unsafe.Pointer(&guard), C.size_t(guardSize))
 	ptr = unsafe.Add(ptr, guardSize)
 
 	*((*uintptr)(ptr)) = size
-	atomic.AddInt64(&c.usage, int64(size))
+	c.stats.RecordAlloc(size)
 	ptr = unsafe.Add(ptr, sizeOfUintptr)
+	setBlockState(ptr, blockStateAllocated)
 
 	ptr2 := unsafe.Add(ptr, size)
 	C.memcpy(ptr2, unsafe.Pointer(&guard), C.size_t(guardSize))
 
+	if c.opts.TrackAllocations {
+		c.trackBlock(ptr, size)
+	}
+
 	return unsafe.Pointer(ptr)
 }
 
 func (c *DebugCAllocator) Free(ptr unsafe.Pointer) {
 	if ptr != nil {
+		var blk liveBlock
+
+		// Validate the pointer before touching the block, if possible
+		if c.opts.TrackAllocations {
+			blk = c.untrackBlock(ptr)
+		}
+
+		switch blockState(ptr) {
+		case blockStateAllocated:
+		case blockStateFreed:
+			panic(fmt.Sprintf("DebugCAllocator::double free detected at %p", ptr))
+		default:
+			panic(fmt.Sprintf("DebugCAllocator::free of foreign pointer detected at %p", ptr))
+		}
+
 		realPtr := unsafe.Add(ptr, -(sizeOfUintptr + guardSize))
-		if C.memcmp(realPtr, unsafe.Pointer(&guard), C.size_t(guardSize)) != 0 {
+		if !checkPreGuard(ptr) {
 			panic("DebugCAllocator::bufferoverflow/pre detected")
 		}
 
-		sizePtr := unsafe.Add(ptr, -sizeOfUintptr)
-		size := *((*uintptr)(sizePtr))
-		newUsage := atomic.AddInt64(&c.usage, -int64(size))
-		if int(newUsage) < 0 {
+		size := blockSize(ptr)
+		newUsage := c.stats.RecordFree(size)
+		if newUsage < 0 {
 			panic("DebugCAllocator usage below 0")
 		}
 
-		ptr = unsafe.Add(ptr, size)
-		if C.memcmp(ptr, unsafe.Pointer(&guard), C.size_t(guardSize)) != 0 {
+		if !checkPostGuard(ptr, size) {
 			panic("DebugCAllocator::bufferoverflow/post detected")
 		}
 
+		setBlockState(ptr, blockStateFreed)
+
+		if c.opts.QuarantineSize > 0 {
+			c.quarantineBlock(ptr, blk)
+			return
+		}
+
 		C.free(realPtr)
 	}
 }
 
 func (c *DebugCAllocator) Usage() int64 {
-	return atomic.LoadInt64(&c.usage)
+	return c.stats.InUse()
+}
+
+// Stats returns the usage statistics.
+func (c *DebugCAllocator) Stats() allocator.Stats {
+	return c.stats.Snapshot()
+}
+
+func (c *DebugCAllocator) trackBlock(ptr unsafe.Pointer, size uintptr) {
+	stack := make([]uintptr, c.opts.StackDepth)
+	stack = stack[:runtime.Callers(3, stack)]
+
+	c.liveMtx.Lock()
+	c.live[ptr] = liveBlock{
+		size:  size,
+		stack: stack,
+	}
+	c.liveMtx.Unlock()
+}
+
+func (c *DebugCAllocator) untrackBlock(ptr unsafe.Pointer) liveBlock {
+	c.liveMtx.Lock()
+	blk, ok := c.live[ptr]
+	delete(c.live, ptr)
+	c.liveMtx.Unlock()
+
+	if !ok {
+		if qb, found := c.findQuarantined(ptr); found {
+			panic(fmt.Sprintf("DebugCAllocator::double free detected at %p\nallocated at:\n%vfreed at:\n%v",
+				ptr, formatStack(qb.allocStack), formatStack(qb.freeStack)))
+		}
+		panic(fmt.Sprintf("DebugCAllocator::free of foreign pointer or double free detected at %p", ptr))
+	}
+	return blk
+}
+
+func blockState(ptr unsafe.Pointer) uintptr {
+	return *((*uintptr)(unsafe.Add(ptr, -int(stateOffset))))
+}
+
+func setBlockState(ptr unsafe.Pointer, state uintptr) {
+	*((*uintptr)(unsafe.Add(ptr, -int(stateOffset)))) = state
+}
+
+func blockSize(ptr unsafe.Pointer) uintptr {
+	return *((*uintptr)(unsafe.Add(ptr, -sizeOfUintptr)))
+}
+
+func checkPreGuard(ptr unsafe.Pointer) bool {
+	realPtr := unsafe.Add(ptr, -(sizeOfUintptr + guardSize))
+	return C.memcmp(realPtr, unsafe.Pointer(&guard), C.size_t(guardSize)) == 0
+}
+
+func checkPostGuard(ptr unsafe.Pointer, size uintptr) bool {
+	return C.memcmp(unsafe.Add(ptr, size), unsafe.Pointer(&guard), C.size_t(guardSize)) == 0
 }
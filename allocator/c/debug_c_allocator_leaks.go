@@ -0,0 +1,114 @@
+//go:build cgo
+
+package c
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+	"unsafe"
+)
+
+// -----------------------------------------------------------------------------
+
+// Leak groups the live blocks that were allocated from the same call stack.
+type Leak struct {
+	Stack []uintptr
+	Count int
+	Bytes uintptr
+}
+
+// -----------------------------------------------------------------------------
+
+// Leaks returns the blocks that are still allocated grouped by the call stack that allocated them, largest first.
+// It returns nil if allocation tracking is disabled.
+func (c *DebugCAllocator) Leaks() []Leak {
+	if !c.opts.TrackAllocations {
+		return nil
+	}
+
+	c.liveMtx.Lock()
+	defer c.liveMtx.Unlock()
+
+	groups := make(map[string]*Leak)
+	for _, blk := range c.live {
+		key := stackKey(blk.stack)
+		l, ok := groups[key]
+		if !ok {
+			l = &Leak{
+				Stack: blk.stack,
+			}
+			groups[key] = l
+		}
+		l.Count += 1
+		l.Bytes += blk.size
+	}
+
+	leaks := make([]Leak, 0, len(groups))
+	for _, l := range groups {
+		leaks = append(leaks, *l)
+	}
+	sort.Slice(leaks, func(i, j int) bool {
+		if leaks[i].Bytes != leaks[j].Bytes {
+			return leaks[i].Bytes > leaks[j].Bytes
+		}
+		return leaks[i].Count > leaks[j].Count
+	})
+	return leaks
+}
+
+// CheckAll verifies the guard zones of every live block without waiting for them to be freed. Allocation tracking
+// must be enabled. If the quarantine is enabled, the poison of every quarantined block is verified too.
+func (c *DebugCAllocator) CheckAll() error {
+	if !c.opts.TrackAllocations {
+		return errors.New("DebugCAllocator: allocation tracking is disabled")
+	}
+
+	c.liveMtx.Lock()
+	defer c.liveMtx.Unlock()
+
+	errs := make([]error, 0)
+	for ptr, blk := range c.live {
+		if !checkPreGuard(ptr) {
+			errs = append(errs, fmt.Errorf("DebugCAllocator::bufferoverflow/pre detected at %p, allocated at:\n%v",
+				ptr, formatStack(blk.stack)))
+		}
+		if !checkPostGuard(ptr, blk.size) {
+			errs = append(errs, fmt.Errorf("DebugCAllocator::bufferoverflow/post detected at %p, allocated at:\n%v",
+				ptr, formatStack(blk.stack)))
+		}
+	}
+	if err := c.checkQuarantine(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// String returns a human-readable description of the leak.
+func (l Leak) String() string {
+	return fmt.Sprintf("%v bytes in %v block(s) allocated at:\n%v", l.Bytes, l.Count, formatStack(l.Stack))
+}
+
+func stackKey(stack []uintptr) string {
+	if len(stack) == 0 {
+		return ""
+	}
+	return string(unsafe.Slice((*byte)(unsafe.Pointer(&stack[0])), len(stack)*int(unsafe.Sizeof(stack[0]))))
+}
+
+func formatStack(stack []uintptr) string {
+	sb := strings.Builder{}
+	frames := runtime.CallersFrames(stack)
+	for {
+		frame, more := frames.Next()
+		if len(frame.Function) > 0 {
+			sb.WriteString(fmt.Sprintf("\t%v\n\t\t%v:%v\n", frame.Function, frame.File, frame.Line))
+		}
+		if !more {
+			break
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,167 @@
+//go:build cgo
+
+package c
+
+// #include <memory.h>
+// #include <stdlib.h>
+import "C"
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"unsafe"
+)
+
+// -----------------------------------------------------------------------------
+
+const poisonByte = 0xDD
+const poisonWord = uintptr(0xDDDDDDDDDDDDDDDD & uint64(^uintptr(0)))
+
+// -----------------------------------------------------------------------------
+
+type quarantineQueue struct {
+	blocks []quarantinedBlock
+	head   int
+	count  int
+	bytes  uintptr
+}
+
+type quarantinedBlock struct {
+	ptr        unsafe.Pointer
+	size       uintptr
+	allocStack []uintptr
+	freeStack  []uintptr
+}
+
+// -----------------------------------------------------------------------------
+
+// FlushQuarantine verifies and releases every block held in the quarantine.
+func (c *DebugCAllocator) FlushQuarantine() {
+	if c.opts.QuarantineSize <= 0 {
+		return
+	}
+
+	for {
+		c.quarantineMtx.Lock()
+		qb, ok := c.quarantine.pop()
+		c.quarantineMtx.Unlock()
+		if !ok {
+			break
+		}
+		releaseQuarantinedBlock(qb)
+	}
+}
+
+func (c *DebugCAllocator) quarantineBlock(ptr unsafe.Pointer, blk liveBlock) {
+	freeStack := make([]uintptr, c.opts.StackDepth)
+	freeStack = freeStack[:runtime.Callers(3, freeStack)]
+
+	size := blockSize(ptr)
+	C.memset(ptr, poisonByte, C.size_t(size))
+
+	evicted := make([]quarantinedBlock, 0, 1)
+
+	c.quarantineMtx.Lock()
+	for c.quarantine.count == len(c.quarantine.blocks) ||
+		(c.opts.QuarantineBytes > 0 && c.quarantine.count > 0 && c.quarantine.bytes+size > c.opts.QuarantineBytes) {
+		qb, _ := c.quarantine.pop()
+		evicted = append(evicted, qb)
+	}
+	c.quarantine.push(quarantinedBlock{
+		ptr:        ptr,
+		size:       size,
+		allocStack: blk.stack,
+		freeStack:  freeStack,
+	})
+	c.quarantineMtx.Unlock()
+
+	for _, qb := range evicted {
+		releaseQuarantinedBlock(qb)
+	}
+}
+
+func (c *DebugCAllocator) checkQuarantine() error {
+	if c.opts.QuarantineSize <= 0 {
+		return nil
+	}
+
+	c.quarantineMtx.Lock()
+	defer c.quarantineMtx.Unlock()
+
+	errs := make([]error, 0)
+	for idx := 0; idx < c.quarantine.count; idx++ {
+		qb := &c.quarantine.blocks[(c.quarantine.head+idx)%len(c.quarantine.blocks)]
+		if err := qb.check(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (c *DebugCAllocator) findQuarantined(ptr unsafe.Pointer) (quarantinedBlock, bool) {
+	if c.opts.QuarantineSize <= 0 {
+		return quarantinedBlock{}, false
+	}
+
+	c.quarantineMtx.Lock()
+	defer c.quarantineMtx.Unlock()
+
+	for idx := 0; idx < c.quarantine.count; idx++ {
+		qb := &c.quarantine.blocks[(c.quarantine.head+idx)%len(c.quarantine.blocks)]
+		if qb.ptr == ptr {
+			return *qb, true
+		}
+	}
+	return quarantinedBlock{}, false
+}
+
+func releaseQuarantinedBlock(qb quarantinedBlock) {
+	if err := qb.check(); err != nil {
+		panic(err.Error())
+	}
+	C.free(unsafe.Add(qb.ptr, -(sizeOfUintptr + guardSize)))
+}
+
+func (qb *quarantinedBlock) check() error {
+	if !checkPreGuard(qb.ptr) || !checkPostGuard(qb.ptr, qb.size) || !isPoisoned(qb.ptr, qb.size) {
+		return fmt.Errorf("DebugCAllocator::use-after-free detected at %p (%v bytes)\nallocated at:\n%vfreed at:\n%v",
+			qb.ptr, qb.size, formatStack(qb.allocStack), formatStack(qb.freeStack))
+	}
+	return nil
+}
+
+func (q *quarantineQueue) push(qb quarantinedBlock) {
+	q.blocks[(q.head+q.count)%len(q.blocks)] = qb
+	q.count += 1
+	q.bytes += qb.size
+}
+
+func (q *quarantineQueue) pop() (quarantinedBlock, bool) {
+	if q.count == 0 {
+		return quarantinedBlock{}, false
+	}
+	qb := q.blocks[q.head]
+	q.blocks[q.head] = quarantinedBlock{}
+	q.head = (q.head + 1) % len(q.blocks)
+	q.count -= 1
+	q.bytes -= qb.size
+	return qb, true
+}
+
+func isPoisoned(ptr unsafe.Pointer, size uintptr) bool {
+	// Compare a word at a time while the pointer is aligned, then byte by byte
+	for size >= unsafe.Sizeof(uintptr(0)) && uintptr(ptr)%unsafe.Sizeof(uintptr(0)) == 0 {
+		if *(*uintptr)(ptr) != poisonWord {
+			return false
+		}
+		ptr = unsafe.Add(ptr, unsafe.Sizeof(uintptr(0)))
+		size -= unsafe.Sizeof(uintptr(0))
+	}
+	for ; size > 0; size-- {
+		if *(*byte)(ptr) != poisonByte {
+			return false
+		}
+		ptr = unsafe.Add(ptr, 1)
+	}
+	return true
+}
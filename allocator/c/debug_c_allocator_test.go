@@ -0,0 +1,141 @@
+//go:build cgo
+
+package c_test
+
+import (
+	"strings"
+	"testing"
+	"unsafe"
+
+	"github.com/mxmauro/unmanagedgen/allocator/c"
+)
+
+// -----------------------------------------------------------------------------
+
+func TestDebugCAllocatorLeaks(t *testing.T) {
+	alloc := c.NewWithDebugOptions(c.DebugOptions{
+		TrackAllocations: true,
+	})
+
+	ptrs := make([]unsafe.Pointer, 0)
+	for idx := 0; idx < 3; idx++ {
+		ptrs = append(ptrs, leakyAlloc(alloc))
+	}
+	alloc.Free(alloc.Alloc(16))
+
+	leaks := alloc.Leaks()
+	if len(leaks) != 1 || leaks[0].Count != 3 || leaks[0].Bytes != 3*32 {
+		t.Fatalf("unexpected leak report: %v", leaks)
+	}
+	if !strings.Contains(leaks[0].String(), "leakyAlloc") {
+		t.Fatalf("leak report does not contain the allocation site:\n%v", leaks[0])
+	}
+
+	for _, ptr := range ptrs {
+		alloc.Free(ptr)
+	}
+	if len(alloc.Leaks()) != 0 || alloc.Usage() != 0 {
+		t.Fatalf("blocks are still reported as live")
+	}
+}
+
+func TestDebugCAllocatorCheckAll(t *testing.T) {
+	alloc := c.NewWithDebugOptions(c.DebugOptions{
+		TrackAllocations: true,
+	})
+
+	ptr := alloc.Alloc(32)
+	if err := alloc.CheckAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Overwrite the first byte of the post guard zone
+	buf := unsafe.Slice((*byte)(ptr), 33)
+	saved := buf[32]
+	buf[32] = 0
+	if err := alloc.CheckAll(); err == nil || !strings.Contains(err.Error(), "bufferoverflow/post") {
+		t.Fatalf("buffer overflow not detected [err=%v]", err)
+	}
+	buf[32] = saved
+
+	alloc.Free(ptr)
+}
+
+func leakyAlloc(alloc *c.DebugCAllocator) unsafe.Pointer {
+	return alloc.Alloc(32)
+}
+
+func TestDebugCAllocatorQuarantine(t *testing.T) {
+	alloc := c.NewWithDebugOptions(c.DebugOptions{
+		QuarantineSize: 4,
+	})
+
+	// Blocks leaving the quarantine untouched must be released silently
+	for idx := 0; idx < 10; idx++ {
+		alloc.Free(alloc.Alloc(64))
+	}
+	if err := alloc.CheckAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	ptr := alloc.Alloc(64)
+	alloc.Free(ptr)
+	*(*byte)(unsafe.Add(ptr, 10)) = 1
+
+	err := alloc.CheckAll()
+	if err == nil || !strings.Contains(err.Error(), "use-after-free") {
+		t.Fatalf("use-after-free not detected by CheckAll [err=%v]", err)
+	}
+	if !strings.Contains(err.Error(), "TestDebugCAllocatorQuarantine") {
+		t.Fatalf("report does not contain the allocation and free stacks:\n%v", err)
+	}
+
+	defer func() {
+		if r := recover(); r == nil || !strings.Contains(r.(string), "use-after-free") {
+			t.Fatalf("use-after-free not detected when leaving the quarantine [panic=%v]", r)
+		}
+	}()
+	alloc.FlushQuarantine()
+}
+
+func TestDebugCAllocatorInvalidFree(t *testing.T) {
+	var buf [128]byte
+
+	tracked := c.NewWithDebugOptions(c.DebugOptions{
+		TrackAllocations: true,
+	})
+	quarantined := c.NewWithDebugOptions(c.DebugOptions{
+		QuarantineSize: 4,
+	})
+	defer quarantined.FlushQuarantine()
+
+	expectPanic(t, "double free", func() {
+		alloc := c.NewWithDebug()
+		ptr := alloc.Alloc(256)
+		alloc.Free(ptr)
+		alloc.Free(ptr)
+	})
+	expectPanic(t, "double free", func() {
+		ptr := quarantined.Alloc(16)
+		quarantined.Free(ptr)
+		quarantined.Free(ptr)
+	})
+	expectPanic(t, "foreign pointer", func() {
+		c.NewWithDebug().Free(unsafe.Pointer(&buf[64]))
+	})
+	expectPanic(t, "foreign pointer", func() {
+		tracked.Free(unsafe.Pointer(&buf[64]))
+	})
+}
+
+func expectPanic(t *testing.T, msg string, fn func()) {
+	t.Helper()
+
+	defer func() {
+		r := recover()
+		if s, ok := r.(string); !ok || !strings.Contains(s, msg) {
+			t.Errorf("expected a panic containing \"%v\" [panic=%v]", msg, r)
+		}
+	}()
+	fn()
+}
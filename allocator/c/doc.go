@@ -0,0 +1,5 @@
+// Package c provides allocator.Allocator implementations backed by the C runtime malloc/free.
+//
+// All the allocators in this package require cgo. The parent allocator package does not, so builds using a pure-Go
+// allocator can be compiled with CGO_ENABLED=0.
+package c
@@ -0,0 +1,19 @@
+package allocator
+
+import (
+	"errors"
+)
+
+// -----------------------------------------------------------------------------
+
+var (
+	// ErrOutOfMemory is returned by the generated Try* functions when the allocator cannot satisfy a request.
+	ErrOutOfMemory = errors.New("cannot allocate memory")
+
+	// ErrSizeOverflow is returned by the generated Try* functions when the requested size is negative or does not
+	// fit in an uintptr.
+	ErrSizeOverflow = errors.New("size out of range")
+
+	// ErrIndexOutOfRange is returned by the generated Try* functions when an index is outside the valid range.
+	ErrIndexOutOfRange = errors.New("index out of range")
+)
@@ -0,0 +1,3 @@
+// Package faultinject provides an allocator wrapper that makes allocations fail on purpose, to test how code
+// handles out-of-memory conditions.
+package faultinject
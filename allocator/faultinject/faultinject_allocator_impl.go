@@ -0,0 +1,124 @@
+package faultinject
+
+import (
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+
+	"github.com/mxmauro/unmanagedgen/allocator"
+)
+
+// -----------------------------------------------------------------------------
+
+// Options defines which allocations fail. An allocation fails if any of the enabled conditions is met.
+type Options struct {
+	// FailAt, if not zero, makes the Nth allocation fail. Allocations are numbered starting at 1.
+	FailAt uint64
+
+	// Probability, if greater than zero, makes allocations fail randomly with the given probability (0 to 1).
+	Probability float64
+
+	// Seed initializes the random source used with Probability, so failures can be reproduced.
+	Seed int64
+
+	// SizeThreshold, if not zero, makes every allocation of this size or bigger fail.
+	SizeThreshold uintptr
+}
+
+// Injector is an allocator.Middleware that makes some allocations return nil. Every allocation made through the
+// chain is an allocation point, including the ones made by AllocZeroed, AllocAligned and Realloc.
+type Injector struct {
+	opts     Options
+	allocs   atomic.Uint64
+	failures atomic.Uint64
+
+	rndMtx sync.Mutex
+	rnd    *rand.Rand
+}
+
+// -----------------------------------------------------------------------------
+
+// New creates a new fault-injection allocator on top of the given one. It returns the allocator, which exposes the
+// same optional interfaces as allocator.Chain, and the injector, which counts the allocations and failures.
+func New(base allocator.Allocator, opts Options) (allocator.Allocator, *Injector) {
+	inj := NewInjector(opts)
+	return allocator.Chain(base, inj), inj
+}
+
+// NewInjector creates a new fault-injection middleware, to be combined with others in allocator.Chain.
+func NewInjector(opts Options) *Injector {
+	inj := &Injector{
+		opts: opts,
+	}
+	if opts.Probability > 0 {
+		inj.rnd = rand.New(rand.NewSource(opts.Seed))
+	}
+	return inj
+}
+
+func (inj *Injector) Alloc(size uintptr, next allocator.AllocFunc) unsafe.Pointer {
+	if inj.inject(size) {
+		return nil
+	}
+	return next(size)
+}
+
+func (inj *Injector) Free(ptr unsafe.Pointer, next allocator.FreeFunc) {
+	next(ptr)
+}
+
+// Allocations returns the number of allocations requested so far, including the failed ones.
+func (inj *Injector) Allocations() uint64 {
+	return inj.allocs.Load()
+}
+
+// Failures returns the number of allocations that were made to fail.
+func (inj *Injector) Failures() uint64 {
+	return inj.failures.Load()
+}
+
+// inject counts an allocation and returns true if it must fail.
+func (inj *Injector) inject(size uintptr) bool {
+	n := inj.allocs.Add(1)
+	if inj.shouldFail(n, size) {
+		inj.failures.Add(1)
+		return true
+	}
+	return false
+}
+
+func (inj *Injector) shouldFail(n uint64, size uintptr) bool {
+	if inj.opts.FailAt > 0 && n == inj.opts.FailAt {
+		return true
+	}
+	if inj.opts.SizeThreshold > 0 && size >= inj.opts.SizeThreshold {
+		return true
+	}
+	if inj.rnd != nil {
+		inj.rndMtx.Lock()
+		f := inj.rnd.Float64()
+		inj.rndMtx.Unlock()
+		if f < inj.opts.Probability {
+			return true
+		}
+	}
+	return false
+}
+
+// InjectEach runs fn repeatedly, making the first allocation fail in the first run, the second one in the next run,
+// and so on, until a run completes without reaching the failure point. Each run gets a new allocator on top of base,
+// along with its injector. It returns the number of allocation points exercised.
+//
+// fn must undo all of its work before returning, so leaks can be checked on base after each run.
+func InjectEach(base allocator.Allocator, fn func(alloc allocator.Allocator, inj *Injector)) int {
+	for n := uint64(1); ; n++ {
+		alloc, inj := New(base, Options{
+			FailAt: n,
+		})
+		fn(alloc, inj)
+		if inj.Failures() == 0 {
+			return int(n - 1)
+		}
+	}
+}
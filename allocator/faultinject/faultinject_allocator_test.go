@@ -0,0 +1,200 @@
+package faultinject_test
+
+import (
+	"testing"
+	"unsafe"
+
+	"github.com/mxmauro/unmanagedgen/allocator"
+	"github.com/mxmauro/unmanagedgen/allocator/faultinject"
+	"github.com/mxmauro/unmanagedgen/allocator/internal/testalloc"
+)
+
+// -----------------------------------------------------------------------------
+
+type reallocHeapAllocator struct {
+	*testalloc.HeapAllocator
+}
+
+type bulkHeapAllocator struct {
+	*testalloc.HeapAllocator
+}
+
+// -----------------------------------------------------------------------------
+
+func TestFaultInjectionAllocator(t *testing.T) {
+	base := testalloc.NewHeap()
+
+	alloc, inj := faultinject.New(base, faultinject.Options{
+		FailAt:        2,
+		SizeThreshold: 1024,
+	})
+	ptr1 := alloc.Alloc(16)
+	if ptr1 == nil {
+		t.Fatalf("first allocation failed")
+	}
+	if alloc.Alloc(16) != nil {
+		t.Fatalf("second allocation did not fail")
+	}
+	if alloc.Alloc(1024) != nil {
+		t.Fatalf("allocation above the size threshold did not fail")
+	}
+	ptr2 := alloc.Alloc(16)
+	if ptr2 == nil {
+		t.Fatalf("fourth allocation failed")
+	}
+	alloc.Free(ptr1)
+	alloc.Free(ptr2)
+
+	if inj.Allocations() != 4 || inj.Failures() != 2 || base.Live() != 0 {
+		t.Fatalf("unexpected counters [allocs=%v, failures=%v, live=%v]", inj.Allocations(), inj.Failures(),
+			base.Live())
+	}
+}
+
+func TestFaultInjectionAllocatorOptionalInterfaces(t *testing.T) {
+	alloc, _ := faultinject.New(testalloc.NewHeap(), faultinject.Options{})
+	if _, ok := alloc.(allocator.Reallocator); ok {
+		t.Fatalf("allocator implements Reallocator but the base allocator does not")
+	}
+	if _, ok := alloc.(allocator.StatsProvider); ok {
+		t.Fatalf("allocator implements StatsProvider but the base allocator does not")
+	}
+
+	base := &reallocHeapAllocator{
+		HeapAllocator: testalloc.NewHeap(),
+	}
+	alloc, inj := faultinject.New(base, faultinject.Options{
+		FailAt: 2,
+	})
+	ra, ok := alloc.(allocator.Reallocator)
+	if !ok {
+		t.Fatalf("allocator does not implement Reallocator")
+	}
+	za, ok := alloc.(allocator.ZeroAllocator)
+	if !ok {
+		t.Fatalf("allocator does not implement ZeroAllocator")
+	}
+
+	ptr := za.AllocZeroed(16)
+	*(*byte)(ptr) = 42
+	if ra.Realloc(ptr, 64) != nil {
+		t.Fatalf("reallocation did not fail")
+	}
+	ptr = ra.Realloc(ptr, 64)
+	if ptr == nil || *(*byte)(ptr) != 42 {
+		t.Fatalf("block not preserved after a failed reallocation")
+	}
+	alloc.Free(ptr)
+
+	if inj.Allocations() != 3 || inj.Failures() != 1 || base.Live() != 0 {
+		t.Fatalf("unexpected counters [allocs=%v, failures=%v, live=%v]", inj.Allocations(), inj.Failures(),
+			base.Live())
+	}
+
+	bulkBase := &bulkHeapAllocator{
+		HeapAllocator: testalloc.NewHeap(),
+	}
+	alloc, inj = faultinject.New(bulkBase, faultinject.Options{
+		FailAt: 2,
+	})
+	if !allocator.FreeIsNoop(alloc) {
+		t.Fatalf("FreeIsNoop not forwarded")
+	}
+	sp, ok := alloc.(allocator.StatsProvider)
+	if !ok {
+		t.Fatalf("allocator does not implement StatsProvider")
+	}
+	ptr = allocator.AllocZeroedAligned(alloc, 32, 256)
+	if ptr == nil || uintptr(ptr)%256 != 0 {
+		t.Fatalf("over-aligned allocation failed")
+	}
+	if allocator.AllocZeroedAligned(alloc, 32, 256) != nil {
+		t.Fatalf("aligned allocation did not fail")
+	}
+	if sp.Stats().BytesInUse != 32 || inj.Allocations() != 2 || inj.Failures() != 1 {
+		t.Fatalf("unexpected counters [inUse=%v, allocs=%v, failures=%v]", sp.Stats().BytesInUse, inj.Allocations(),
+			inj.Failures())
+	}
+}
+
+func TestFaultInjectionAllocatorRandom(t *testing.T) {
+	run := func() []bool {
+		alloc, _ := faultinject.New(testalloc.NewHeap(), faultinject.Options{
+			Probability: 0.5,
+			Seed:        42,
+		})
+		failed := make([]bool, 100)
+		for idx := range failed {
+			ptr := alloc.Alloc(16)
+			failed[idx] = ptr == nil
+			alloc.Free(ptr)
+		}
+		return failed
+	}
+
+	first := run()
+	second := run()
+	failures := 0
+	for idx := range first {
+		if first[idx] != second[idx] {
+			t.Fatalf("failures are not reproducible with the same seed")
+		}
+		if first[idx] {
+			failures++
+		}
+	}
+	if failures == 0 || failures == len(first) {
+		t.Fatalf("unexpected failure count [%v]", failures)
+	}
+}
+
+func TestInjectEach(t *testing.T) {
+	base := testalloc.NewHeap()
+
+	runs := 0
+	points := faultinject.InjectEach(base, func(alloc allocator.Allocator, _ *faultinject.Injector) {
+		runs++
+		ptrs := make([]unsafe.Pointer, 0, 3)
+		for idx := 0; idx < 3; idx++ {
+			ptr := alloc.Alloc(16)
+			if ptr == nil {
+				break
+			}
+			ptrs = append(ptrs, ptr)
+		}
+		for _, ptr := range ptrs {
+			alloc.Free(ptr)
+		}
+		if base.Live() != 0 {
+			t.Fatalf("leak after run %v", runs)
+		}
+	})
+	if points != 3 || runs != 4 {
+		t.Fatalf("unexpected number of runs [points=%v, runs=%v]", points, runs)
+	}
+}
+
+func (a *reallocHeapAllocator) AllocZeroed(size uintptr) unsafe.Pointer {
+	return a.Alloc(size)
+}
+
+func (a *reallocHeapAllocator) Realloc(ptr unsafe.Pointer, size uintptr) unsafe.Pointer {
+	newPtr := a.Alloc(size)
+	copy(a.Block(newPtr), a.Block(ptr))
+	a.Free(ptr)
+	return newPtr
+}
+
+func (a *bulkHeapAllocator) AllocAligned(size uintptr, alignment uintptr) unsafe.Pointer {
+	return a.AllocWithAlignment(size, alignment)
+}
+
+func (a *bulkHeapAllocator) FreeIsNoop() bool {
+	return true
+}
+
+func (a *bulkHeapAllocator) Stats() allocator.Stats {
+	return allocator.Stats{
+		BytesInUse: a.InUse(),
+	}
+}
@@ -0,0 +1,6 @@
+// Package guarded provides a sampling allocator that places a small fraction of the allocations in pages surrounded
+// by inaccessible guard pages, in the spirit of GWP-ASan. Buffer overflows and use-after-free on those blocks fault
+// at the offending instruction instead of being found, if ever, when the block is released.
+//
+// It is available on Linux and macOS.
+package guarded
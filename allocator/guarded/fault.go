@@ -0,0 +1,65 @@
+//go:build linux || darwin
+
+package guarded
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+// -----------------------------------------------------------------------------
+
+// FaultKind classifies a memory error found on a guarded block.
+type FaultKind string
+
+const (
+	FaultBufferOverflow  FaultKind = "buffer-overflow"
+	FaultBufferUnderflow FaultKind = "buffer-underflow"
+	FaultUseAfterFree    FaultKind = "use-after-free"
+	FaultDoubleFree      FaultKind = "double-free"
+	FaultInvalidFree     FaultKind = "invalid-free"
+	FaultWildAccess      FaultKind = "wild-access"
+)
+
+// Fault describes a memory error on a guarded block. It is used as the panic value of HandleFault and of Free.
+type Fault struct {
+	Kind       FaultKind
+	Addr       uintptr
+	BlockAddr  uintptr
+	BlockSize  uintptr
+	AllocStack []uintptr
+	FreeStack  []uintptr
+}
+
+// -----------------------------------------------------------------------------
+
+func (f *Fault) Error() string {
+	sb := strings.Builder{}
+	_, _ = fmt.Fprintf(&sb, "GuardedAllocator::%v detected at %#x", f.Kind, f.Addr)
+	if f.BlockAddr != 0 {
+		_, _ = fmt.Fprintf(&sb, " [block=%#x, size=%v]", f.BlockAddr, f.BlockSize)
+	}
+	if len(f.AllocStack) > 0 {
+		sb.WriteString("\nallocated at:\n")
+		sb.WriteString(formatStack(f.AllocStack))
+	}
+	if len(f.FreeStack) > 0 {
+		sb.WriteString("freed at:\n")
+		sb.WriteString(formatStack(f.FreeStack))
+	}
+	return sb.String()
+}
+
+func formatStack(stack []uintptr) string {
+	sb := strings.Builder{}
+	frames := runtime.CallersFrames(stack)
+	for {
+		frame, more := frames.Next()
+		_, _ = fmt.Fprintf(&sb, "\t%v\n\t\t%v:%v\n", frame.Function, frame.File, frame.Line)
+		if !more {
+			break
+		}
+	}
+	return sb.String()
+}
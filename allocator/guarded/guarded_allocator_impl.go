@@ -0,0 +1,351 @@
+//go:build linux || darwin
+
+package guarded
+
+import (
+	"os"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"unsafe"
+
+	"github.com/mxmauro/unmanagedgen/allocator"
+)
+
+// -----------------------------------------------------------------------------
+
+const (
+	DefaultSampleRate = 1000
+	DefaultMaxSlots   = 256
+
+	defaultStackDepth = 16
+
+	// slackPattern fills the bytes between the end of a guarded block and its guard page
+	slackPattern = 0xAB
+)
+
+// -----------------------------------------------------------------------------
+
+// Options configures a GuardedAllocator.
+type Options struct {
+	// SampleRate sends one of every SampleRate allocations to a guarded page. Defaults to 1000. Use 1 to guard every
+	// allocation that fits in a page.
+	SampleRate uint64
+
+	// MaxSlots is the number of guarded pages. When all of them are in use, allocations go to the base allocator.
+	// Defaults to 256.
+	MaxSlots int
+
+	// StackDepth is the maximum number of frames recorded when a guarded block is allocated or freed. Defaults to 16.
+	StackDepth int
+
+	// OnFault, if set, is called by HandleFault and Free before panicking with a fault report.
+	OnFault func(f *Fault)
+}
+
+// GuardedAllocator sends a sample of the allocations to pages surrounded by guard pages and passes the rest to a
+// base allocator.
+//
+// Guarded blocks are aligned to allocator.DefaultAlignment and placed as close to the end of their page as that
+// allows, so overflows hit the next guard page right away. The few slack bytes left before it, if the size is not a
+// multiple of the alignment, are filled with a pattern that is checked on Free, so small overflows are reported then.
+// Freed pages are made inaccessible and are reused in FIFO order, so use-after-free is caught for as long as
+// possible. The cost for non-sampled allocations is an atomic increment on Alloc and a range check on Free.
+type GuardedAllocator struct {
+	base     allocator.Allocator
+	opts     Options
+	pageSize uintptr
+	pool     unsafe.Pointer
+	poolAddr uintptr
+	poolSize uintptr
+	counter  atomic.Uint64
+	inUse    atomic.Int64
+	stats    allocator.StatsCounter
+
+	mtx       sync.Mutex
+	slots     []slot
+	freeSlots []int
+	freeHead  int
+	freeCount int
+}
+
+type slotState int
+
+const (
+	slotUnused slotState = iota
+	slotAllocated
+	slotFreed
+)
+
+type slot struct {
+	state      slotState
+	ptr        uintptr
+	size       uintptr
+	allocStack []uintptr
+	freeStack  []uintptr
+}
+
+// -----------------------------------------------------------------------------
+
+// New creates a new guarded allocator on top of the given one. It returns an error if the guarded pages cannot be
+// reserved.
+func New(base allocator.Allocator, opts Options) (*GuardedAllocator, error) {
+	if opts.SampleRate == 0 {
+		opts.SampleRate = DefaultSampleRate
+	}
+	if opts.MaxSlots <= 0 {
+		opts.MaxSlots = DefaultMaxSlots
+	}
+	if opts.StackDepth <= 0 {
+		opts.StackDepth = defaultStackDepth
+	}
+
+	a := GuardedAllocator{
+		base:      base,
+		opts:      opts,
+		pageSize:  uintptr(os.Getpagesize()),
+		slots:     make([]slot, opts.MaxSlots),
+		freeSlots: make([]int, opts.MaxSlots),
+		freeCount: opts.MaxSlots,
+	}
+	for idx := range a.freeSlots {
+		a.freeSlots[idx] = idx
+	}
+
+	// Slots and guard pages alternate, starting and ending with a guard page. Everything starts inaccessible.
+	a.poolSize = a.pageSize * uintptr(2*opts.MaxSlots+1)
+	b, err := syscall.Mmap(-1, 0, int(a.poolSize), syscall.PROT_NONE, syscall.MAP_ANON|syscall.MAP_PRIVATE)
+	if err != nil {
+		return nil, err
+	}
+	a.pool = unsafe.Pointer(unsafe.SliceData(b))
+	a.poolAddr = uintptr(a.pool)
+
+	return &a, nil
+}
+
+func (a *GuardedAllocator) Alloc(size uintptr) unsafe.Pointer {
+	if size == 0 || size > a.pageSize || a.counter.Add(1)%a.opts.SampleRate != 0 {
+		return a.base.Alloc(size)
+	}
+
+	ptr := a.allocGuarded(size)
+	if ptr == nil {
+		return a.base.Alloc(size)
+	}
+	return ptr
+}
+
+func (a *GuardedAllocator) Free(ptr unsafe.Pointer) {
+	addr := uintptr(ptr)
+	if addr < a.poolAddr || addr >= a.poolAddr+a.poolSize {
+		a.base.Free(ptr)
+		return
+	}
+
+	a.mtx.Lock()
+
+	pageIdx := (addr - a.poolAddr) / a.pageSize
+	slotIdx := int(pageIdx / 2)
+	s := &a.slots[slotIdx]
+	if pageIdx%2 == 0 || s.state != slotAllocated || s.ptr != addr {
+		f := &Fault{
+			Kind: FaultInvalidFree,
+			Addr: addr,
+		}
+		if pageIdx%2 == 1 && s.state == slotFreed && s.ptr == addr {
+			f = a.newFault(FaultDoubleFree, addr, s)
+		}
+		a.mtx.Unlock()
+		a.reportFault(f)
+	}
+	if corrupted, found := a.checkSlack(slotIdx, s); found {
+		f := a.newFault(FaultBufferOverflow, corrupted, s)
+		a.mtx.Unlock()
+		a.reportFault(f)
+	}
+
+	s.state = slotFreed
+	s.freeStack = a.callers()
+	if err := syscall.Mprotect(a.slotPage(slotIdx), syscall.PROT_NONE); err != nil {
+		a.mtx.Unlock()
+		panic("GuardedAllocator: unable to protect page [err=" + err.Error() + "]")
+	}
+	a.freeSlots[(a.freeHead+a.freeCount)%len(a.freeSlots)] = slotIdx
+	a.freeCount += 1
+	a.inUse.Add(-1)
+	a.stats.RecordFree(a.pageSize)
+
+	a.mtx.Unlock()
+}
+
+// GuardedInUse returns the number of live blocks placed in guarded pages.
+func (a *GuardedAllocator) GuardedInUse() int {
+	return int(a.inUse.Load())
+}
+
+// Stats returns the usage statistics of the guarded blocks, each one accounted for its whole page, added to the ones
+// of the base allocator if it is an allocator.StatsProvider. The high-water mark is the sum of both, so it can be
+// higher than the real one. Guard pages are never accessible and take no physical memory, so they are not counted.
+func (a *GuardedAllocator) Stats() allocator.Stats {
+	st := a.stats.Snapshot()
+	sp, ok := a.base.(allocator.StatsProvider)
+	if !ok {
+		return st
+	}
+
+	baseSt := sp.Stats()
+	st.BytesInUse += baseSt.BytesInUse
+	st.HighWaterMark += baseSt.HighWaterMark
+	st.Allocs += baseSt.Allocs
+	st.Frees += baseSt.Frees
+	if baseSt.SizeHistogram != nil && len(baseSt.SizeHistogram.Counts) == len(st.SizeHistogram.Counts) {
+		for idx, count := range baseSt.SizeHistogram.Counts {
+			st.SizeHistogram.Counts[idx] += count
+		}
+	}
+	return st
+}
+
+// Describe returns a report about the given address if it belongs to the guarded pages.
+func (a *GuardedAllocator) Describe(addr uintptr) (*Fault, bool) {
+	if addr < a.poolAddr || addr >= a.poolAddr+a.poolSize {
+		return nil, false
+	}
+
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	pageIdx := int((addr - a.poolAddr) / a.pageSize)
+	if pageIdx%2 == 1 {
+		s := &a.slots[pageIdx/2]
+		switch s.state {
+		case slotFreed:
+			return a.newFault(FaultUseAfterFree, addr, s), true
+		case slotAllocated:
+			if addr < s.ptr {
+				return a.newFault(FaultBufferUnderflow, addr, s), true
+			}
+			return a.newFault(FaultBufferOverflow, addr, s), true
+		}
+	} else {
+		// A guard page. Blame the block on its left first, as blocks are placed at the end of their pages.
+		if pageIdx > 0 && a.slots[pageIdx/2-1].state != slotUnused {
+			s := &a.slots[pageIdx/2-1]
+			if s.state == slotFreed {
+				return a.newFault(FaultUseAfterFree, addr, s), true
+			}
+			return a.newFault(FaultBufferOverflow, addr, s), true
+		}
+		if pageIdx/2 < len(a.slots) && a.slots[pageIdx/2].state != slotUnused {
+			s := &a.slots[pageIdx/2]
+			if s.state == slotFreed {
+				return a.newFault(FaultUseAfterFree, addr, s), true
+			}
+			return a.newFault(FaultBufferUnderflow, addr, s), true
+		}
+	}
+
+	return &Fault{
+		Kind: FaultWildAccess,
+		Addr: addr,
+	}, true
+}
+
+// HandleFault turns a memory fault on a guarded page into a panic with a *Fault that includes the allocation and free
+// stacks of the block involved. Other panics are propagated unchanged.
+//
+// It must be deferred by goroutines that called debug.SetPanicOnFault(true). Otherwise, the Go runtime aborts the
+// process on the first fault, reporting the faulting address and stack only.
+func (a *GuardedAllocator) HandleFault() {
+	r := recover()
+	if r == nil {
+		return
+	}
+	if re, ok := r.(interface{ Addr() uintptr }); ok {
+		if f, found := a.Describe(re.Addr()); found {
+			a.reportFault(f)
+		}
+	}
+	panic(r)
+}
+
+func (a *GuardedAllocator) allocGuarded(size uintptr) unsafe.Pointer {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	if a.freeCount == 0 {
+		return nil
+	}
+	slotIdx := a.freeSlots[a.freeHead]
+
+	page := a.slotPage(slotIdx)
+	if err := syscall.Mprotect(page, syscall.PROT_READ|syscall.PROT_WRITE); err != nil {
+		return nil
+	}
+	a.freeHead = (a.freeHead + 1) % len(a.freeSlots)
+	a.freeCount -= 1
+
+	s := &a.slots[slotIdx]
+	if s.state == slotFreed {
+		clear(page)
+	}
+	// Place the block right before the guard page, keeping the alignment, and fill the slack so Free can check it
+	offset := (a.pageSize - size) &^ (allocator.DefaultAlignment - 1)
+	slack := page[offset+size:]
+	for idx := range slack {
+		slack[idx] = slackPattern
+	}
+	*s = slot{
+		state:      slotAllocated,
+		ptr:        uintptr(unsafe.Pointer(unsafe.SliceData(page))) + offset,
+		size:       size,
+		allocStack: a.callers(),
+	}
+	a.inUse.Add(1)
+	a.stats.RecordAlloc(a.pageSize)
+
+	return unsafe.Pointer(unsafe.SliceData(page[offset:]))
+}
+
+// checkSlack returns the address of the first modified byte between the end of the block and the guard page, if
+// any. Must be called with the mutex held.
+func (a *GuardedAllocator) checkSlack(slotIdx int, s *slot) (uintptr, bool) {
+	page := a.slotPage(slotIdx)
+	offset := s.ptr + s.size - uintptr(unsafe.Pointer(unsafe.SliceData(page)))
+	for idx, v := range page[offset:] {
+		if v != slackPattern {
+			return s.ptr + s.size + uintptr(idx), true
+		}
+	}
+	return 0, false
+}
+
+func (a *GuardedAllocator) slotPage(slotIdx int) []byte {
+	ptr := unsafe.Add(a.pool, a.pageSize*uintptr(2*slotIdx+1))
+	return unsafe.Slice((*byte)(ptr), a.pageSize)
+}
+
+func (a *GuardedAllocator) callers() []uintptr {
+	stack := make([]uintptr, a.opts.StackDepth)
+	return stack[:runtime.Callers(3, stack)]
+}
+
+func (a *GuardedAllocator) newFault(kind FaultKind, addr uintptr, s *slot) *Fault {
+	return &Fault{
+		Kind:       kind,
+		Addr:       addr,
+		BlockAddr:  s.ptr,
+		BlockSize:  s.size,
+		AllocStack: s.allocStack,
+		FreeStack:  s.freeStack,
+	}
+}
+
+func (a *GuardedAllocator) reportFault(f *Fault) {
+	if a.opts.OnFault != nil {
+		a.opts.OnFault(f)
+	}
+	panic(f)
+}
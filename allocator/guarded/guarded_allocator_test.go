@@ -0,0 +1,148 @@
+//go:build linux || darwin
+
+package guarded_test
+
+import (
+	"os"
+	"runtime/debug"
+	"strings"
+	"testing"
+	"unsafe"
+
+	"github.com/mxmauro/unmanagedgen/allocator"
+	"github.com/mxmauro/unmanagedgen/allocator/guarded"
+	"github.com/mxmauro/unmanagedgen/allocator/internal/testalloc"
+	"github.com/mxmauro/unmanagedgen/allocator/slab"
+)
+
+// -----------------------------------------------------------------------------
+
+func TestGuardedAllocatorSampling(t *testing.T) {
+	base := testalloc.NewHeap()
+	alloc, err := guarded.New(base, guarded.Options{
+		SampleRate: 4,
+		MaxSlots:   2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ptrs := make([]unsafe.Pointer, 0, 16)
+	for idx := 0; idx < 16; idx++ {
+		ptr := alloc.Alloc(100)
+		buf := unsafe.Slice((*byte)(ptr), 100)
+		for idx2 := range buf {
+			if buf[idx2] != 0 {
+				t.Fatalf("guarded block not zeroed")
+			}
+			buf[idx2] = 0xFF
+		}
+		ptrs = append(ptrs, ptr)
+	}
+	if alloc.GuardedInUse() != 2 || base.Live() != 14 {
+		t.Fatalf("unexpected sampling [guarded=%v, base=%v]", alloc.GuardedInUse(), base.Live())
+	}
+	st := alloc.Stats()
+	if st.Allocs != 2 || st.BytesInUse != 2*uint64(os.Getpagesize()) {
+		t.Fatalf("unexpected stats [allocs=%v, inUse=%v]", st.Allocs, st.BytesInUse)
+	}
+
+	for _, ptr := range ptrs {
+		alloc.Free(ptr)
+	}
+	if alloc.GuardedInUse() != 0 || base.Live() != 0 || alloc.Stats().BytesInUse != 0 || alloc.Stats().Frees != 2 {
+		t.Fatalf("blocks not released")
+	}
+}
+
+func TestGuardedAllocatorStats(t *testing.T) {
+	base := slab.New(testalloc.NewHeap())
+	alloc, err := guarded.New(base, guarded.Options{
+		SampleRate: 2,
+		MaxSlots:   4,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ptr1 := alloc.Alloc(100)
+	ptr2 := alloc.Alloc(100)
+	st := alloc.Stats()
+	if st.Allocs != 2 || st.BytesInUse != uint64(os.Getpagesize())+base.Stats().BytesInUse ||
+		base.Stats().BytesInUse == 0 || st.SizeHistogram == nil {
+		t.Fatalf("unexpected stats [allocs=%v, inUse=%v]", st.Allocs, st.BytesInUse)
+	}
+	alloc.Free(ptr1)
+	alloc.Free(ptr2)
+	if st = alloc.Stats(); st.Frees != 2 || st.BytesInUse != 0 {
+		t.Fatalf("unexpected stats [frees=%v, inUse=%v]", st.Frees, st.BytesInUse)
+	}
+	base.Release()
+}
+
+func TestGuardedAllocatorFaults(t *testing.T) {
+	alloc, err := guarded.New(testalloc.NewHeap(), guarded.Options{
+		SampleRate: 1,
+		MaxSlots:   4,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ptr := alloc.Alloc(32)
+	f := catchFault(alloc, func() {
+		*(*byte)(unsafe.Add(ptr, 32)) = 1
+	})
+	if f == nil || f.Kind != guarded.FaultBufferOverflow || f.BlockAddr != uintptr(ptr) {
+		t.Fatalf("overflow not detected [fault=%v]", f)
+	}
+	if !strings.Contains(f.Error(), "TestGuardedAllocatorFaults") {
+		t.Fatalf("allocation stack not reported:\n%v", f.Error())
+	}
+
+	// Blocks keep the default alignment whatever their size, and small overflows into the slack before the guard
+	// page are reported on Free
+	for _, size := range []uintptr{17, 19} {
+		ptr2 := alloc.Alloc(size)
+		if uintptr(ptr2)%allocator.DefaultAlignment != 0 {
+			t.Fatalf("block of %v bytes not aligned [ptr=%p]", size, ptr2)
+		}
+		*(*byte)(unsafe.Add(ptr2, size)) = 1
+		f = catchFault(alloc, func() {
+			alloc.Free(ptr2)
+		})
+		if f == nil || f.Kind != guarded.FaultBufferOverflow || f.Addr != uintptr(ptr2)+size {
+			t.Fatalf("overflow into the slack not detected [fault=%v]", f)
+		}
+		*(*byte)(unsafe.Add(ptr2, size)) = 0xAB
+		alloc.Free(ptr2)
+	}
+
+	alloc.Free(ptr)
+	f = catchFault(alloc, func() {
+		_ = *(*byte)(ptr)
+	})
+	if f == nil || f.Kind != guarded.FaultUseAfterFree || len(f.FreeStack) == 0 {
+		t.Fatalf("use-after-free not detected [fault=%v]", f)
+	}
+
+	f = catchFault(alloc, func() {
+		alloc.Free(ptr)
+	})
+	if f == nil || f.Kind != guarded.FaultDoubleFree {
+		t.Fatalf("double free not detected [fault=%v]", f)
+	}
+}
+
+func catchFault(alloc *guarded.GuardedAllocator, fn func()) (f *guarded.Fault) {
+	defer func() {
+		f, _ = recover().(*guarded.Fault)
+	}()
+	defer alloc.HandleFault()
+
+	old := debug.SetPanicOnFault(true)
+	defer debug.SetPanicOnFault(old)
+
+	fn()
+	return nil
+}
@@ -0,0 +1,75 @@
+package allocator
+
+import (
+	"encoding/binary"
+	"math"
+	"unsafe"
+)
+
+// -----------------------------------------------------------------------------
+
+const (
+	hashMultiplier = 0x9e3779b97f4a7c15
+	hashNotNil     = 0x5bd1e995
+)
+
+// -----------------------------------------------------------------------------
+
+// HashUint64 mixes x into the hash h. It is used by the generated Hash methods and is not meant to be
+// cryptographically secure.
+func HashUint64(h uint64, x uint64) uint64 {
+	h ^= x
+	h *= hashMultiplier
+	h ^= h >> 29
+	h *= hashMultiplier
+	h ^= h >> 32
+	return h
+}
+
+// HashNil mixes into the hash h whether a pointer, slice or object is nil, so nil and empty values hash
+// differently.
+func HashNil(h uint64, isNil bool) uint64 {
+	if isNil {
+		return HashUint64(h, 0)
+	}
+	return HashUint64(h, hashNotNil)
+}
+
+// HashMem mixes the contents of the given memory block into the hash h.
+func HashMem(h uint64, ptr unsafe.Pointer, size uintptr) uint64 {
+	if size > 0 {
+		buf := unsafe.Slice((*byte)(ptr), size)
+		for len(buf) >= 8 {
+			h = HashUint64(h, binary.LittleEndian.Uint64(buf))
+			buf = buf[8:]
+		}
+		if len(buf) > 0 {
+			var tail [8]byte
+
+			copy(tail[:], buf)
+			h = HashUint64(h, binary.LittleEndian.Uint64(tail[:]))
+		}
+	}
+	return HashUint64(h, uint64(size))
+}
+
+// HashString mixes the contents of s into the hash h.
+func HashString(h uint64, s string) uint64 {
+	return HashMem(h, unsafe.Pointer(unsafe.StringData(s)), uintptr(len(s)))
+}
+
+// HashFloat64 mixes f into the hash h. Positive and negative zeroes hash the same because they compare equal.
+func HashFloat64(h uint64, f float64) uint64 {
+	if f == 0 {
+		f = 0
+	}
+	return HashUint64(h, math.Float64bits(f))
+}
+
+// HashBool mixes b into the hash h.
+func HashBool(h uint64, b bool) uint64 {
+	if b {
+		return HashUint64(h, 1)
+	}
+	return HashUint64(h, 0)
+}
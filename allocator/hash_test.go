@@ -0,0 +1,27 @@
+package allocator_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/mxmauro/unmanagedgen/allocator"
+)
+
+// -----------------------------------------------------------------------------
+
+func TestHash(t *testing.T) {
+	if allocator.HashString(0, "hello") != allocator.HashString(0, "hello") {
+		t.Fatalf("hash is not deterministic")
+	}
+	if allocator.HashString(0, "hello") == allocator.HashString(0, "hellO") ||
+		allocator.HashString(0, "") == allocator.HashString(0, "\x00") ||
+		allocator.HashString(0, "hello") == allocator.HashString(1, "hello") {
+		t.Fatalf("different inputs have the same hash")
+	}
+	if allocator.HashFloat64(0, 0) != allocator.HashFloat64(0, math.Copysign(0, -1)) {
+		t.Fatalf("positive and negative zeroes hash differently")
+	}
+	if allocator.HashNil(0, true) == allocator.HashNil(0, false) {
+		t.Fatalf("nil and non-nil values have the same hash")
+	}
+}
@@ -1,8 +1,5 @@
 package allocator
 
-// #include <memory.h>
-// #include <stdlib.h>
-import "C"
 import (
 	"unsafe"
 )
@@ -14,12 +11,22 @@ var safeMul = uintptr(1) << (4 * unsafe.Sizeof(sizeOfPtr))
 
 // -----------------------------------------------------------------------------
 
+// ZeroMem fills the given memory block with zeroes. The compiler turns the clear into a runtime memclr call, so
+// no cgo is required.
 func ZeroMem(ptr unsafe.Pointer, size uintptr) {
-	C.memset(ptr, 0, C.size_t(size))
+	if size == 0 {
+		return
+	}
+	clear(unsafe.Slice((*byte)(ptr), size))
 }
 
+// CopyMem copies size bytes from src to dest. Blocks may overlap. The compiler turns the copy into a runtime memmove
+// call, so no cgo is required.
 func CopyMem(dest, src unsafe.Pointer, size uintptr) {
-	C.memcpy(dest, src, C.size_t(size))
+	if size == 0 {
+		return
+	}
+	copy(unsafe.Slice((*byte)(dest), size), unsafe.Slice((*byte)(src), size))
 }
 
 func AddUintptr(a, b uintptr) (uintptr, bool) {
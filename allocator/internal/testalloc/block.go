@@ -0,0 +1,36 @@
+package testalloc
+
+import (
+	"unsafe"
+)
+
+// -----------------------------------------------------------------------------
+
+// Block is a block filled with a known byte, so tests can detect when an allocator hands out overlapping blocks or
+// modifies a live one.
+type Block struct {
+	Ptr  unsafe.Pointer
+	Size uintptr
+	Fill byte
+}
+
+// -----------------------------------------------------------------------------
+
+// FillBlock sets every byte of the block to its fill value.
+func FillBlock(b Block) {
+	buf := unsafe.Slice((*byte)(b.Ptr), b.Size)
+	for idx := range buf {
+		buf[idx] = b.Fill
+	}
+}
+
+// CheckBlock returns true if every byte of the block still holds its fill value.
+func CheckBlock(b Block) bool {
+	buf := unsafe.Slice((*byte)(b.Ptr), b.Size)
+	for _, v := range buf {
+		if v != b.Fill {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,2 @@
+// Package testalloc provides the backing allocator and the helpers shared by the tests of the allocator packages.
+package testalloc
@@ -0,0 +1,79 @@
+package testalloc
+
+import (
+	"sync"
+	"unsafe"
+
+	"github.com/mxmauro/unmanagedgen/allocator"
+)
+
+// -----------------------------------------------------------------------------
+
+// HeapAllocator takes its blocks from the Go heap and keeps them referenced until they are freed, so they can be
+// used to back the allocators under test without cgo. Blocks are aligned to allocator.DefaultAlignment. It is safe
+// for concurrent use.
+type HeapAllocator struct {
+	mtx    sync.Mutex
+	blocks map[unsafe.Pointer][]byte
+	inUse  uint64
+}
+
+// -----------------------------------------------------------------------------
+
+// NewHeap creates a new heap allocator.
+func NewHeap() *HeapAllocator {
+	return &HeapAllocator{
+		blocks: make(map[unsafe.Pointer][]byte),
+	}
+}
+
+func (a *HeapAllocator) Alloc(size uintptr) unsafe.Pointer {
+	return a.AllocWithAlignment(size, allocator.DefaultAlignment)
+}
+
+// AllocWithAlignment allocates a block with the given alignment, which must be a power of two. It is not named
+// AllocAligned so the allocator does not implement allocator.AlignedAllocator unless a test wants it to.
+func (a *HeapAllocator) AllocWithAlignment(size uintptr, alignment uintptr) unsafe.Pointer {
+	// Keep at least one byte of capacity so empty blocks get their own address
+	buf := make([]byte, max(size, 1)+alignment-1)
+	offset := -uintptr(unsafe.Pointer(unsafe.SliceData(buf))) & (alignment - 1)
+	buf = buf[offset : offset+size : offset+max(size, 1)]
+	ptr := unsafe.Pointer(unsafe.SliceData(buf))
+
+	a.mtx.Lock()
+	a.blocks[ptr] = buf
+	a.inUse += uint64(size)
+	a.mtx.Unlock()
+	return ptr
+}
+
+func (a *HeapAllocator) Free(ptr unsafe.Pointer) {
+	a.mtx.Lock()
+	a.inUse -= uint64(len(a.blocks[ptr]))
+	delete(a.blocks, ptr)
+	a.mtx.Unlock()
+}
+
+// Block returns the memory of a live block, or nil if ptr is not one.
+func (a *HeapAllocator) Block(ptr unsafe.Pointer) []byte {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	return a.blocks[ptr]
+}
+
+// Live returns the number of blocks not freed yet.
+func (a *HeapAllocator) Live() int {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	return len(a.blocks)
+}
+
+// InUse returns the number of bytes in the blocks not freed yet.
+func (a *HeapAllocator) InUse() uint64 {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	return a.inUse
+}
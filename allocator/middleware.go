@@ -0,0 +1,202 @@
+package allocator
+
+import (
+	"math/bits"
+	"unsafe"
+)
+
+// -----------------------------------------------------------------------------
+
+// AllocFunc is the next step of an allocation in a middleware chain.
+type AllocFunc func(size uintptr) unsafe.Pointer
+
+// FreeFunc is the next step of a release in a middleware chain.
+type FreeFunc func(ptr unsafe.Pointer)
+
+// Middleware adds behavior to the calls made to an allocator. Each method receives the next step of the chain and
+// must call it to get or release memory, unless the middleware decides to fail the allocation by returning nil.
+//
+// Middlewares must return the pointers they get from the next step unchanged, so the optional interfaces of the base
+// allocator keep working on them. Reallocations are seen as an allocation followed by the release of the old block,
+// unless the block is resized in place, in which case the allocation returns the address of the live block and no
+// release follows.
+type Middleware interface {
+	Alloc(size uintptr, next AllocFunc) unsafe.Pointer
+	Free(ptr unsafe.Pointer, next FreeFunc)
+}
+
+type reallocFunc func(ptr unsafe.Pointer, size uintptr) unsafe.Pointer
+
+type chain struct {
+	base   Allocator
+	mws    []Middleware
+	alloc  AllocFunc
+	zero   AllocFunc
+	free   FreeFunc
+	noFree FreeFunc
+
+	// One step per power of two alignment, indexed by its exponent. Empty if the base allocator is not an
+	// AlignedAllocator.
+	aligned []AllocFunc
+}
+
+// StatsProvider and Reallocator cannot be emulated on top of the base allocator, so they are implemented by separate
+// types that are embedded along with the chain only when the base allocator supports them
+type statsChain struct {
+	sp StatsProvider
+}
+
+type reallocChain struct {
+	c       *chain
+	realloc reallocFunc
+}
+
+// -----------------------------------------------------------------------------
+
+// Chain returns an allocator that passes every call through the given middlewares before reaching base. The first
+// middleware is the outermost one.
+//
+// The returned allocator always implements ZeroAllocator, AlignedAllocator and BulkReleaser, reporting what base
+// does: AllocZeroed falls back to Alloc and ZeroMem, and AllocAligned returns nil for alignments greater than
+// DefaultAlignment if base is not an AlignedAllocator, the same as AllocZeroedAligned. StatsProvider and Reallocator
+// are implemented only if base does.
+func Chain(base Allocator, mws ...Middleware) Allocator {
+	c := &chain{
+		base: base,
+		mws:  mws,
+	}
+	c.alloc = c.wrapAlloc(base.Alloc)
+	c.zero = c.wrapAlloc(func(size uintptr) unsafe.Pointer {
+		return AllocZeroed(base, size)
+	})
+	c.free = c.wrapFree(base.Free)
+	c.noFree = c.wrapFree(func(_ unsafe.Pointer) {})
+	if aa, ok := base.(AlignedAllocator); ok {
+		c.aligned = make([]AllocFunc, bits.UintSize)
+		for idx := range c.aligned {
+			alignment := uintptr(1) << idx
+			c.aligned[idx] = c.wrapAlloc(func(size uintptr) unsafe.Pointer {
+				return aa.AllocAligned(size, alignment)
+			})
+		}
+	}
+
+	sp, isStats := base.(StatsProvider)
+	ra, isRealloc := base.(Reallocator)
+	var rc reallocChain
+	if isRealloc {
+		rc.c = c
+		rc.realloc = c.wrapRealloc(ra.Realloc)
+	}
+
+	switch {
+	case isStats && isRealloc:
+		return &struct {
+			*chain
+			statsChain
+			reallocChain
+		}{c, statsChain{sp}, rc}
+	case isStats:
+		return &struct {
+			*chain
+			statsChain
+		}{c, statsChain{sp}}
+	case isRealloc:
+		return &struct {
+			*chain
+			reallocChain
+		}{c, rc}
+	}
+	return c
+}
+
+func (c *chain) Alloc(size uintptr) unsafe.Pointer {
+	return c.alloc(size)
+}
+
+func (c *chain) Free(ptr unsafe.Pointer) {
+	c.free(ptr)
+}
+
+// AllocZeroed allocates a zeroed block through the chain.
+func (c *chain) AllocZeroed(size uintptr) unsafe.Pointer {
+	return c.zero(size)
+}
+
+// AllocAligned allocates an aligned block through the chain. It returns nil if alignment is not a power of two, or if
+// it is greater than DefaultAlignment and the base allocator is not an AlignedAllocator.
+func (c *chain) AllocAligned(size uintptr, alignment uintptr) unsafe.Pointer {
+	if alignment == 0 || alignment&(alignment-1) != 0 {
+		return nil
+	}
+	if len(c.aligned) == 0 {
+		if alignment > DefaultAlignment {
+			return nil
+		}
+		return c.alloc(size)
+	}
+	return c.aligned[bits.TrailingZeros(uint(alignment))](size)
+}
+
+// FreeIsNoop returns true if the base allocator releases memory in bulk.
+func (c *chain) FreeIsNoop() bool {
+	return FreeIsNoop(c.base)
+}
+
+func (c *chain) wrapAlloc(last AllocFunc) AllocFunc {
+	next := last
+	for idx := len(c.mws) - 1; idx >= 0; idx-- {
+		mw := c.mws[idx]
+		n := next
+		next = func(size uintptr) unsafe.Pointer {
+			return mw.Alloc(size, n)
+		}
+	}
+	return next
+}
+
+func (c *chain) wrapFree(last FreeFunc) FreeFunc {
+	next := last
+	for idx := len(c.mws) - 1; idx >= 0; idx-- {
+		mw := c.mws[idx]
+		n := next
+		next = func(ptr unsafe.Pointer) {
+			mw.Free(ptr, n)
+		}
+	}
+	return next
+}
+
+func (c *chain) wrapRealloc(last reallocFunc) reallocFunc {
+	next := last
+	for idx := len(c.mws) - 1; idx >= 0; idx-- {
+		mw := c.mws[idx]
+		n := next
+		next = func(ptr unsafe.Pointer, size uintptr) unsafe.Pointer {
+			// Middlewares only see the size, so the block being resized is carried by the next step
+			return mw.Alloc(size, func(size uintptr) unsafe.Pointer {
+				return n(ptr, size)
+			})
+		}
+	}
+	return next
+}
+
+// Stats returns the statistics of the base allocator.
+func (sc *statsChain) Stats() Stats {
+	return sc.sp.Stats()
+}
+
+// Realloc resizes a block through the chain.
+func (rc *reallocChain) Realloc(ptr unsafe.Pointer, size uintptr) unsafe.Pointer {
+	if ptr == nil {
+		return rc.c.alloc(size)
+	}
+
+	newPtr := rc.realloc(ptr, size)
+	if newPtr != nil && newPtr != ptr {
+		// The old block was released by the reallocation, let middlewares know about it
+		rc.c.noFree(ptr)
+	}
+	return newPtr
+}
@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"sync"
+	"sync/atomic"
+	"unsafe"
+
+	"github.com/mxmauro/unmanagedgen/allocator"
+)
+
+// -----------------------------------------------------------------------------
+
+// SizeCounter is a middleware that counts allocations by requested size.
+type SizeCounter struct {
+	mtx    sync.Mutex
+	counts map[uintptr]uint64
+	failed atomic.Uint64
+	frees  atomic.Uint64
+}
+
+// -----------------------------------------------------------------------------
+
+// NewSizeCounter creates a new SizeCounter middleware.
+func NewSizeCounter() *SizeCounter {
+	return &SizeCounter{
+		counts: make(map[uintptr]uint64),
+	}
+}
+
+func (m *SizeCounter) Alloc(size uintptr, next allocator.AllocFunc) unsafe.Pointer {
+	ptr := next(size)
+	if ptr == nil {
+		m.failed.Add(1)
+		return nil
+	}
+
+	m.mtx.Lock()
+	m.counts[size] += 1
+	m.mtx.Unlock()
+	return ptr
+}
+
+func (m *SizeCounter) Free(ptr unsafe.Pointer, next allocator.FreeFunc) {
+	if ptr != nil {
+		m.frees.Add(1)
+	}
+	next(ptr)
+}
+
+// Counts returns the number of successful allocations for each requested size.
+func (m *SizeCounter) Counts() map[uintptr]uint64 {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	counts := make(map[uintptr]uint64, len(m.counts))
+	for size, count := range m.counts {
+		counts[size] = count
+	}
+	return counts
+}
+
+// Failed returns the number of allocations that returned nil.
+func (m *SizeCounter) Failed() uint64 {
+	return m.failed.Load()
+}
+
+// Frees returns the number of blocks released.
+func (m *SizeCounter) Frees() uint64 {
+	return m.frees.Load()
+}
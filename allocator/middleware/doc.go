@@ -0,0 +1,3 @@
+// Package middleware provides ready to use allocator.Middleware implementations for tracing, counting and timing
+// allocator calls. Combine them with allocator.Chain.
+package middleware
@@ -0,0 +1,87 @@
+package middleware
+
+import (
+	"sync/atomic"
+	"time"
+	"unsafe"
+
+	"github.com/mxmauro/unmanagedgen/allocator"
+)
+
+// -----------------------------------------------------------------------------
+
+// Latency is a middleware that measures how long the allocator takes to serve Alloc and Free calls.
+type Latency struct {
+	alloc latencyCounter
+	free  latencyCounter
+}
+
+// LatencyStats summarizes the time spent in one kind of call.
+type LatencyStats struct {
+	Calls uint64
+	Total time.Duration
+	Max   time.Duration
+}
+
+type latencyCounter struct {
+	calls atomic.Uint64
+	total atomic.Int64
+	max   atomic.Int64
+}
+
+// -----------------------------------------------------------------------------
+
+// NewLatency creates a new Latency middleware.
+func NewLatency() *Latency {
+	return &Latency{}
+}
+
+func (m *Latency) Alloc(size uintptr, next allocator.AllocFunc) unsafe.Pointer {
+	start := time.Now()
+	ptr := next(size)
+	m.alloc.record(time.Since(start))
+	return ptr
+}
+
+func (m *Latency) Free(ptr unsafe.Pointer, next allocator.FreeFunc) {
+	start := time.Now()
+	next(ptr)
+	m.free.record(time.Since(start))
+}
+
+// AllocStats returns the time spent in Alloc calls.
+func (m *Latency) AllocStats() LatencyStats {
+	return m.alloc.snapshot()
+}
+
+// FreeStats returns the time spent in Free calls.
+func (m *Latency) FreeStats() LatencyStats {
+	return m.free.snapshot()
+}
+
+// Average returns the mean duration of a call.
+func (s LatencyStats) Average() time.Duration {
+	if s.Calls == 0 {
+		return 0
+	}
+	return s.Total / time.Duration(s.Calls)
+}
+
+func (lc *latencyCounter) record(d time.Duration) {
+	lc.calls.Add(1)
+	lc.total.Add(int64(d))
+	for {
+		cur := lc.max.Load()
+		if int64(d) <= cur || lc.max.CompareAndSwap(cur, int64(d)) {
+			break
+		}
+	}
+}
+
+func (lc *latencyCounter) snapshot() LatencyStats {
+	return LatencyStats{
+		Calls: lc.calls.Load(),
+		Total: time.Duration(lc.total.Load()),
+		Max:   time.Duration(lc.max.Load()),
+	}
+}
@@ -0,0 +1,181 @@
+package middleware_test
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+	"unsafe"
+
+	"github.com/mxmauro/unmanagedgen/allocator"
+	"github.com/mxmauro/unmanagedgen/allocator/internal/testalloc"
+	"github.com/mxmauro/unmanagedgen/allocator/middleware"
+)
+
+// -----------------------------------------------------------------------------
+
+type reallocHeapAllocator struct {
+	*testalloc.HeapAllocator
+}
+
+type fullHeapAllocator struct {
+	reallocHeapAllocator
+}
+
+// -----------------------------------------------------------------------------
+
+func TestChain(t *testing.T) {
+	var logBuf bytes.Buffer
+
+	counter := middleware.NewSizeCounter()
+	latency := middleware.NewLatency()
+	alloc := allocator.Chain(testalloc.NewHeap(),
+		middleware.Trace(slog.New(slog.NewTextHandler(&logBuf, nil)), slog.LevelInfo),
+		counter,
+		latency,
+	)
+
+	ptr1 := alloc.Alloc(16)
+	ptr2 := alloc.Alloc(16)
+	ptr3 := allocator.AllocZeroed(alloc, 32)
+	alloc.Free(ptr1)
+	alloc.Free(ptr2)
+	alloc.Free(ptr3)
+
+	counts := counter.Counts()
+	if counts[16] != 2 || counts[32] != 1 || counter.Frees() != 3 {
+		t.Fatalf("unexpected counts [counts=%v, frees=%v]", counts, counter.Frees())
+	}
+	if latency.AllocStats().Calls != 3 || latency.FreeStats().Calls != 3 {
+		t.Fatalf("unexpected latency stats")
+	}
+	if strings.Count(logBuf.String(), "unmanaged alloc") != 3 || strings.Count(logBuf.String(), "unmanaged free") != 3 {
+		t.Fatalf("unexpected trace:\n%v", logBuf.String())
+	}
+
+	if _, ok := alloc.(allocator.Reallocator); ok {
+		t.Fatalf("chain implements Reallocator but the base allocator does not")
+	}
+	if _, ok := alloc.(allocator.StatsProvider); ok {
+		t.Fatalf("chain implements StatsProvider but the base allocator does not")
+	}
+}
+
+func TestChainWithoutOptionalInterfaces(t *testing.T) {
+	base := testalloc.NewHeap()
+	counter := middleware.NewSizeCounter()
+	alloc := allocator.Chain(base, counter)
+
+	ptr1 := allocator.AllocZeroed(alloc, 16)
+	if ptr1 == nil || *(*byte)(ptr1) != 0 {
+		t.Fatalf("zeroed allocation failed")
+	}
+	aa, ok := alloc.(allocator.AlignedAllocator)
+	if !ok {
+		t.Fatalf("chain does not implement AlignedAllocator")
+	}
+	ptr2 := aa.AllocAligned(32, allocator.DefaultAlignment)
+	if ptr2 == nil {
+		t.Fatalf("default aligned allocation failed")
+	}
+	if aa.AllocAligned(32, 256) != nil || allocator.AllocZeroedAligned(alloc, 32, 256) != nil {
+		t.Fatalf("over-aligned allocation succeeded but the base allocator does not support it")
+	}
+	alloc.Free(ptr1)
+	alloc.Free(ptr2)
+
+	counts := counter.Counts()
+	if counts[16] != 1 || counts[32] != 1 || counter.Failed() != 0 || base.Live() != 0 {
+		t.Fatalf("unexpected counts [counts=%v, failed=%v, live=%v]", counts, counter.Failed(), base.Live())
+	}
+}
+
+func TestChainRealloc(t *testing.T) {
+	base := &reallocHeapAllocator{
+		HeapAllocator: testalloc.NewHeap(),
+	}
+	counter := middleware.NewSizeCounter()
+	alloc := allocator.Chain(base, counter)
+
+	ra, ok := alloc.(allocator.Reallocator)
+	if !ok {
+		t.Fatalf("chain does not implement Reallocator")
+	}
+	ptr := alloc.Alloc(16)
+	*(*byte)(ptr) = 42
+	ptr = ra.Realloc(ptr, 64)
+	if *(*byte)(ptr) != 42 {
+		t.Fatalf("contents not preserved")
+	}
+	alloc.Free(ptr)
+
+	counts := counter.Counts()
+	if counts[16] != 1 || counts[64] != 1 || counter.Frees() != 2 || base.Live() != 0 {
+		t.Fatalf("unexpected counts [counts=%v, frees=%v, live=%v]", counts, counter.Frees(), base.Live())
+	}
+}
+
+func TestChainOptionalInterfaces(t *testing.T) {
+	base := &fullHeapAllocator{
+		reallocHeapAllocator: reallocHeapAllocator{
+			HeapAllocator: testalloc.NewHeap(),
+		},
+	}
+	counter := middleware.NewSizeCounter()
+	alloc := allocator.Chain(base, counter)
+
+	if _, ok := alloc.(allocator.Reallocator); !ok {
+		t.Fatalf("chain does not implement Reallocator")
+	}
+	if _, ok := alloc.(allocator.ZeroAllocator); !ok {
+		t.Fatalf("chain does not implement ZeroAllocator")
+	}
+	aa, ok := alloc.(allocator.AlignedAllocator)
+	if !ok {
+		t.Fatalf("chain does not implement AlignedAllocator")
+	}
+	sp, ok := alloc.(allocator.StatsProvider)
+	if !ok {
+		t.Fatalf("chain does not implement StatsProvider")
+	}
+
+	ptr1 := allocator.AllocZeroed(alloc, 64)
+	ptr2 := aa.AllocAligned(32, 256)
+	if uintptr(ptr2)%256 != 0 {
+		t.Fatalf("block not aligned")
+	}
+	if aa.AllocAligned(32, 3) != nil {
+		t.Fatalf("invalid alignment accepted")
+	}
+	if sp.Stats().BytesInUse != 96 {
+		t.Fatalf("unexpected stats [%v]", sp.Stats().BytesInUse)
+	}
+	alloc.Free(ptr1)
+	alloc.Free(ptr2)
+
+	counts := counter.Counts()
+	if counts[64] != 1 || counts[32] != 1 || counter.Frees() != 2 || sp.Stats().BytesInUse != 0 {
+		t.Fatalf("unexpected counts [counts=%v, frees=%v, live=%v]", counts, counter.Frees(), base.Live())
+	}
+}
+
+func (a *reallocHeapAllocator) Realloc(ptr unsafe.Pointer, size uintptr) unsafe.Pointer {
+	newPtr := a.Alloc(size)
+	copy(a.Block(newPtr), a.Block(ptr))
+	a.Free(ptr)
+	return newPtr
+}
+
+func (a *fullHeapAllocator) AllocZeroed(size uintptr) unsafe.Pointer {
+	return a.Alloc(size)
+}
+
+func (a *fullHeapAllocator) AllocAligned(size uintptr, alignment uintptr) unsafe.Pointer {
+	return a.AllocWithAlignment(size, alignment)
+}
+
+func (a *fullHeapAllocator) Stats() allocator.Stats {
+	return allocator.Stats{
+		BytesInUse: a.InUse(),
+	}
+}
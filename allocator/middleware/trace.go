@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"unsafe"
+
+	"github.com/mxmauro/unmanagedgen/allocator"
+)
+
+// -----------------------------------------------------------------------------
+
+type traceMiddleware struct {
+	logger *slog.Logger
+	level  slog.Level
+}
+
+// -----------------------------------------------------------------------------
+
+// Trace returns a middleware that logs every Alloc and Free with the given logger and level. If the logger is nil,
+// slog.Default is used.
+func Trace(logger *slog.Logger, level slog.Level) allocator.Middleware {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &traceMiddleware{
+		logger: logger,
+		level:  level,
+	}
+}
+
+func (m *traceMiddleware) Alloc(size uintptr, next allocator.AllocFunc) unsafe.Pointer {
+	ptr := next(size)
+	if m.logger.Enabled(context.Background(), m.level) {
+		m.logger.LogAttrs(context.Background(), m.level, "unmanaged alloc",
+			slog.Uint64("size", uint64(size)), slog.Any("ptr", ptr))
+	}
+	return ptr
+}
+
+func (m *traceMiddleware) Free(ptr unsafe.Pointer, next allocator.FreeFunc) {
+	if m.logger.Enabled(context.Background(), m.level) {
+		m.logger.LogAttrs(context.Background(), m.level, "unmanaged free", slog.Any("ptr", ptr))
+	}
+	next(ptr)
+}
@@ -0,0 +1,3 @@
+// Package mmap provides a pure-Go allocator.Allocator implementation that obtains memory directly from the operating
+// system using mmap. It does not require cgo nor the C runtime malloc.
+package mmap
@@ -0,0 +1,259 @@
+//go:build unix
+
+package mmap
+
+import (
+	"math/bits"
+	"os"
+	"sync"
+	"syscall"
+	"unsafe"
+
+	"github.com/mxmauro/unmanagedgen/allocator"
+)
+
+// -----------------------------------------------------------------------------
+
+const (
+	// Every block is preceded by a header that links it to its span (or flags it as a large block)
+	blockHeaderSize = unsafe.Sizeof(blockHeader{})
+
+	// Span metadata is stored at the beginning of the span itself so the Go GC never sees it
+	spanHeaderSize = 64
+	spanSize       = 256 * 1024
+
+	minBlockShift = 5  // 32 bytes
+	maxBlockShift = 15 // 32 KiB
+	classesCount  = maxBlockShift - minBlockShift + 1
+
+	maxInt = int(^uint(0) >> 1)
+)
+
+// -----------------------------------------------------------------------------
+
+// MmapAllocator is an allocator that gets memory straight from the OS and manages it with its own free lists.
+//
+// Small blocks are carved from 256 KiB spans, each one dedicated to a power-of-two size class. Blocks larger than
+// 32 KiB get their own mapping. When a span becomes empty, its pages are returned to the OS.
+type MmapAllocator struct {
+	pageSize uintptr
+	classes  [classesCount]sizeClass
+	stats    allocator.StatsCounter
+}
+
+type sizeClass struct {
+	mtx        sync.Mutex
+	partial    *span
+	emptySpans int
+}
+
+type span struct {
+	next      *span
+	prev      *span
+	freeList  unsafe.Pointer
+	bump      uintptr
+	used      int
+	capacity  int
+	class     int
+	blockSize uintptr
+}
+
+type blockHeader struct {
+	span *span   // nil if the block has its own mapping
+	size uintptr // block size for small blocks or mapping length for large ones
+}
+
+// -----------------------------------------------------------------------------
+
+func init() {
+	if unsafe.Sizeof(span{}) > spanHeaderSize {
+		panic("mmap: span header too large")
+	}
+}
+
+// New creates a new mmap-backed allocator.
+func New() *MmapAllocator {
+	return &MmapAllocator{
+		pageSize: uintptr(os.Getpagesize()),
+	}
+}
+
+func (a *MmapAllocator) Alloc(size uintptr) unsafe.Pointer {
+	total, overflow := allocator.AddUintptr(size, blockHeaderSize)
+	if overflow {
+		return nil
+	}
+
+	if total > 1<<maxBlockShift {
+		return a.allocLarge(total)
+	}
+
+	classIdx := 0
+	if total > 1<<minBlockShift {
+		classIdx = bits.Len(uint(total-1)) - minBlockShift
+	}
+	return a.allocSmall(classIdx)
+}
+
+func (a *MmapAllocator) Free(ptr unsafe.Pointer) {
+	if ptr == nil {
+		return
+	}
+
+	hdr := (*blockHeader)(unsafe.Add(ptr, -int(blockHeaderSize)))
+	a.stats.RecordFree(hdr.size)
+	if hdr.span == nil {
+		unmap(unsafe.Pointer(hdr), hdr.size)
+		return
+	}
+
+	s := hdr.span
+	cls := &a.classes[s.class]
+
+	cls.mtx.Lock()
+	defer cls.mtx.Unlock()
+
+	if s.used == s.capacity {
+		// The span was full so it is not in the partial list
+		cls.pushSpan(s)
+	}
+
+	*(*unsafe.Pointer)(unsafe.Pointer(hdr)) = s.freeList
+	s.freeList = unsafe.Pointer(hdr)
+	s.used -= 1
+
+	if s.used == 0 {
+		if cls.emptySpans > 0 {
+			// Keep only one empty span per class, give the rest back
+			cls.unlinkSpan(s)
+			unmap(unsafe.Pointer(s), spanSize)
+		} else {
+			// Release the physical pages but keep the span mapped for reuse
+			releasePages(unsafe.Add(unsafe.Pointer(s), a.pageSize), spanSize-a.pageSize)
+			s.freeList = nil
+			s.bump = spanHeaderSize
+			cls.emptySpans += 1
+		}
+	}
+}
+
+// Stats returns the usage statistics. Sizes account for whole blocks, including headers and padding.
+func (a *MmapAllocator) Stats() allocator.Stats {
+	return a.stats.Snapshot()
+}
+
+func (a *MmapAllocator) allocLarge(total uintptr) unsafe.Pointer {
+	mapLen, overflow := allocator.AddUintptr(total, a.pageSize-1)
+	if overflow {
+		return nil
+	}
+	mapLen &^= a.pageSize - 1
+
+	ptr := mmap(mapLen)
+	if ptr == nil {
+		return nil
+	}
+
+	hdr := (*blockHeader)(ptr)
+	hdr.span = nil
+	hdr.size = mapLen
+	a.stats.RecordAlloc(mapLen)
+	return unsafe.Add(ptr, blockHeaderSize)
+}
+
+func (a *MmapAllocator) allocSmall(classIdx int) unsafe.Pointer {
+	var block unsafe.Pointer
+
+	cls := &a.classes[classIdx]
+
+	cls.mtx.Lock()
+	defer cls.mtx.Unlock()
+
+	s := cls.partial
+	if s == nil {
+		s = newSpan(classIdx)
+		if s == nil {
+			return nil
+		}
+		cls.pushSpan(s)
+		cls.emptySpans += 1
+	}
+
+	if s.used == 0 {
+		cls.emptySpans -= 1
+	}
+
+	if s.freeList != nil {
+		block = s.freeList
+		s.freeList = *(*unsafe.Pointer)(block)
+	} else {
+		block = unsafe.Add(unsafe.Pointer(s), s.bump)
+		s.bump += s.blockSize
+	}
+	s.used += 1
+
+	if s.used == s.capacity {
+		cls.unlinkSpan(s)
+	}
+
+	hdr := (*blockHeader)(block)
+	hdr.span = s
+	hdr.size = s.blockSize
+	a.stats.RecordAlloc(s.blockSize)
+	return unsafe.Add(block, blockHeaderSize)
+}
+
+func (cls *sizeClass) pushSpan(s *span) {
+	s.prev = nil
+	s.next = cls.partial
+	if cls.partial != nil {
+		cls.partial.prev = s
+	}
+	cls.partial = s
+}
+
+func (cls *sizeClass) unlinkSpan(s *span) {
+	if s.prev != nil {
+		s.prev.next = s.next
+	} else {
+		cls.partial = s.next
+	}
+	if s.next != nil {
+		s.next.prev = s.prev
+	}
+	s.next = nil
+	s.prev = nil
+}
+
+func newSpan(classIdx int) *span {
+	ptr := mmap(spanSize)
+	if ptr == nil {
+		return nil
+	}
+
+	s := (*span)(ptr)
+	s.blockSize = uintptr(1) << (classIdx + minBlockShift)
+	s.class = classIdx
+	s.bump = spanHeaderSize
+	s.capacity = int((spanSize - spanHeaderSize) / s.blockSize)
+	return s
+}
+
+func mmap(size uintptr) unsafe.Pointer {
+	if size > uintptr(maxInt) {
+		return nil
+	}
+	b, err := syscall.Mmap(-1, 0, int(size), syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_ANON|syscall.MAP_PRIVATE)
+	if err != nil || len(b) == 0 {
+		return nil
+	}
+	return unsafe.Pointer(unsafe.SliceData(b))
+}
+
+func unmap(ptr unsafe.Pointer, size uintptr) {
+	// syscall.Munmap locates the mapping by its last byte, so rebuilding the slice from base and length is enough
+	err := syscall.Munmap(unsafe.Slice((*byte)(ptr), size))
+	if err != nil {
+		panic("MmapAllocator: unable to unmap memory [err=" + err.Error() + "]")
+	}
+}
@@ -0,0 +1,74 @@
+//go:build unix
+
+package mmap_test
+
+import (
+	"math/rand"
+	"sync"
+	"testing"
+
+	"github.com/mxmauro/unmanagedgen/allocator/internal/testalloc"
+	"github.com/mxmauro/unmanagedgen/allocator/mmap"
+)
+
+// -----------------------------------------------------------------------------
+
+func TestMmapAllocator(t *testing.T) {
+	alloc := mmap.New()
+
+	wg := sync.WaitGroup{}
+	for worker := 0; worker < 4; worker++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+
+			r := rand.New(rand.NewSource(seed))
+			live := make([]testalloc.Block, 0)
+			for idx := 0; idx < 20000; idx++ {
+				if len(live) > 0 && r.Intn(3) == 0 {
+					pos := r.Intn(len(live))
+					if !testalloc.CheckBlock(live[pos]) {
+						t.Errorf("block contents were modified")
+						return
+					}
+					alloc.Free(live[pos].Ptr)
+					live[pos] = live[len(live)-1]
+					live = live[:len(live)-1]
+					continue
+				}
+
+				b := testalloc.Block{
+					Size: randomSize(r),
+					Fill: byte(r.Intn(256)),
+				}
+				b.Ptr = alloc.Alloc(b.Size)
+				if b.Ptr == nil {
+					t.Errorf("cannot allocate %v bytes", b.Size)
+					return
+				}
+				if uintptr(b.Ptr)&15 != 0 {
+					t.Errorf("block is not 16-byte aligned")
+					return
+				}
+				testalloc.FillBlock(b)
+				live = append(live, b)
+			}
+
+			for _, b := range live {
+				if !testalloc.CheckBlock(b) {
+					t.Errorf("block contents were modified")
+					return
+				}
+				alloc.Free(b.Ptr)
+			}
+		}(int64(worker))
+	}
+	wg.Wait()
+}
+
+func randomSize(r *rand.Rand) uintptr {
+	if r.Intn(50) == 0 {
+		return uintptr(32*1024 + r.Intn(256*1024))
+	}
+	return uintptr(r.Intn(2048))
+}
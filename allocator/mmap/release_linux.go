@@ -0,0 +1,14 @@
+//go:build linux
+
+package mmap
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// -----------------------------------------------------------------------------
+
+func releasePages(ptr unsafe.Pointer, size uintptr) {
+	_ = syscall.Madvise(unsafe.Slice((*byte)(ptr), size), syscall.MADV_DONTNEED)
+}
@@ -0,0 +1,13 @@
+//go:build unix && !linux
+
+package mmap
+
+import (
+	"unsafe"
+)
+
+// -----------------------------------------------------------------------------
+
+func releasePages(_ unsafe.Pointer, _ uintptr) {
+	// The syscall package only exposes madvise on Linux, empty spans remain resident elsewhere
+}
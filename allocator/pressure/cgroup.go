@@ -0,0 +1,199 @@
+package pressure
+
+import (
+	"bufio"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// -----------------------------------------------------------------------------
+
+const (
+	DefaultCgroupRoot     = "/sys/fs/cgroup"
+	DefaultCgroupRatio    = 0.9
+	DefaultCgroupInterval = time.Second
+
+	// cgroup v1 reports a huge page-aligned number when there is no limit
+	cgroupV1Unlimited = uint64(1) << 62
+)
+
+// ErrCgroupNotFound is returned by WatchCgroup when the cgroup memory controller files cannot be found.
+var ErrCgroupNotFound = errors.New("cgroup memory controller not found")
+
+// -----------------------------------------------------------------------------
+
+// CgroupOptions configures WatchCgroup.
+type CgroupOptions struct {
+	// Ratio is the fraction of the cgroup limit at which CgroupNearLimit is sent. Defaults to 0.9.
+	Ratio float64
+
+	// Interval is how often the cgroup files are read. Defaults to one second.
+	Interval time.Duration
+
+	// Root is the mount point of the cgroup filesystem. Defaults to /sys/fs/cgroup.
+	Root string
+}
+
+type cgroupFiles struct {
+	usage string
+	limit string
+}
+
+// -----------------------------------------------------------------------------
+
+// WatchCgroup polls the memory usage and limit of the cgroup the process belongs to, and calls cb when usage reaches
+// the configured ratio of the limit and when it drops back. Both cgroup v2 and v1 are supported. Nothing is reported
+// while the cgroup has no limit.
+//
+// The callback runs on a dedicated goroutine. Call the returned function to stop watching.
+func (a *PressureAllocator) WatchCgroup(opts CgroupOptions, cb Callback) (stop func(), err error) {
+	if opts.Ratio <= 0 {
+		opts.Ratio = DefaultCgroupRatio
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultCgroupInterval
+	}
+	if len(opts.Root) == 0 {
+		opts.Root = DefaultCgroupRoot
+	}
+
+	files, err := findCgroupFiles(opts.Root)
+	if err != nil {
+		return nil, err
+	}
+	if _, _, err = files.read(); err != nil {
+		return nil, err
+	}
+
+	done := make(chan struct{})
+	go func() {
+		nearLimit := false
+
+		ticker := time.NewTicker(opts.Interval)
+		defer ticker.Stop()
+
+		for {
+			usage, limit, err := files.read()
+			if err == nil && limit > 0 {
+				near := float64(usage) >= float64(limit)*opts.Ratio
+				if near != nearLimit {
+					nearLimit = near
+					ev := Event{
+						Kind:        CgroupNearLimit,
+						InUse:       a.inUse.Load(),
+						CgroupUsage: usage,
+						CgroupLimit: limit,
+					}
+					if !near {
+						ev.Kind = CgroupRecovered
+					}
+					cb(ev)
+				}
+			}
+
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	once := sync.Once{}
+	return func() {
+		once.Do(func() {
+			close(done)
+		})
+	}, nil
+}
+
+func findCgroupFiles(root string) (cgroupFiles, error) {
+	// cgroup v2: look in the process' own cgroup first, then at the root (usual inside containers)
+	dirs := make([]string, 0, 2)
+	if path, ok := selfCgroupPath(); ok {
+		dirs = append(dirs, filepath.Join(root, path))
+	}
+	dirs = append(dirs, root)
+	for _, dir := range dirs {
+		files := cgroupFiles{
+			usage: filepath.Join(dir, "memory.current"),
+			limit: filepath.Join(dir, "memory.max"),
+		}
+		if fileExists(files.usage) {
+			return files, nil
+		}
+	}
+
+	// cgroup v1
+	files := cgroupFiles{
+		usage: filepath.Join(root, "memory", "memory.usage_in_bytes"),
+		limit: filepath.Join(root, "memory", "memory.limit_in_bytes"),
+	}
+	if fileExists(files.usage) {
+		return files, nil
+	}
+
+	return cgroupFiles{}, ErrCgroupNotFound
+}
+
+// read returns the usage and the limit of the cgroup. The limit is zero if there is none.
+func (f cgroupFiles) read() (usage uint64, limit uint64, err error) {
+	usage, err = readUintFile(f.usage)
+	if err != nil {
+		return
+	}
+	limit, err = readUintFile(f.limit)
+	if err != nil {
+		// The root cgroup has no limit file
+		if errors.Is(err, fs.ErrNotExist) {
+			err = nil
+		}
+		return
+	}
+	if limit >= cgroupV1Unlimited {
+		limit = 0
+	}
+	return
+}
+
+func selfCgroupPath() (string, bool) {
+	f, err := os.Open("/proc/self/cgroup")
+	if err != nil {
+		return "", false
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// The cgroup v2 entry looks like "0::/path"
+		if path, found := strings.CutPrefix(scanner.Text(), "0::"); found {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+func readUintFile(name string) (uint64, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return 0, err
+	}
+	s := strings.TrimSpace(string(data))
+	if s == "max" {
+		return 0, nil
+	}
+	return strconv.ParseUint(s, 10, 64)
+}
+
+func fileExists(name string) bool {
+	_, err := os.Stat(name)
+	return err == nil
+}
@@ -0,0 +1,4 @@
+// Package pressure provides an allocator wrapper that notifies registered callbacks when unmanaged memory usage
+// crosses thresholds or when the process gets close to its cgroup memory limit, so caches can evict entries before
+// memory runs out.
+package pressure
@@ -0,0 +1,202 @@
+package pressure
+
+import (
+	"sync"
+	"sync/atomic"
+	"unsafe"
+
+	"github.com/mxmauro/unmanagedgen/allocator"
+)
+
+// -----------------------------------------------------------------------------
+
+// Every block is prefixed with its size so Free can update the usage. 16 bytes keep the user data aligned.
+const headerSize = 16
+
+// -----------------------------------------------------------------------------
+
+// EventKind identifies why a callback was invoked.
+type EventKind int
+
+const (
+	// ThresholdExceeded is sent when usage goes above a registered threshold.
+	ThresholdExceeded EventKind = iota
+
+	// ThresholdRecovered is sent when usage drops back to or below a threshold that was exceeded.
+	ThresholdRecovered
+
+	// CgroupNearLimit is sent when the cgroup memory usage reaches the configured ratio of its limit.
+	CgroupNearLimit
+
+	// CgroupRecovered is sent when the cgroup memory usage drops back below the configured ratio.
+	CgroupRecovered
+)
+
+// Event describes a pressure notification.
+type Event struct {
+	Kind EventKind
+
+	// InUse is the number of bytes allocated through the PressureAllocator.
+	InUse uint64
+
+	// Threshold is the threshold crossed. Only set for threshold events.
+	Threshold uint64
+
+	// CgroupUsage and CgroupLimit are the memory usage and limit of the cgroup. Only set for cgroup events.
+	CgroupUsage uint64
+	CgroupLimit uint64
+}
+
+// Callback receives pressure notifications. Threshold callbacks run synchronously on the goroutine whose Alloc or
+// Free crossed the threshold, so they should be short. They may free memory through the same allocator.
+type Callback func(ev Event)
+
+// PressureAllocator tracks the memory allocated through it and notifies the registered callbacks.
+type PressureAllocator struct {
+	base  allocator.Allocator
+	inUse atomic.Uint64
+	stats allocator.StatsCounter
+
+	mtx        sync.Mutex
+	thresholds atomic.Pointer[[]*threshold]
+}
+
+type threshold struct {
+	bytes    uint64
+	cb       Callback
+	exceeded atomic.Bool
+}
+
+// -----------------------------------------------------------------------------
+
+// New creates a new pressure allocator on top of the given one.
+func New(base allocator.Allocator) *PressureAllocator {
+	return &PressureAllocator{
+		base: base,
+	}
+}
+
+func (a *PressureAllocator) Alloc(size uintptr) unsafe.Pointer {
+	total, overflow := allocator.AddUintptr(size, headerSize)
+	if overflow {
+		return nil
+	}
+
+	ptr := a.base.Alloc(total)
+	if ptr == nil {
+		return nil
+	}
+	*((*uintptr)(ptr)) = size
+	a.stats.RecordAlloc(size)
+
+	inUse := a.inUse.Add(uint64(size))
+	if ths := a.thresholds.Load(); ths != nil {
+		for _, th := range *ths {
+			if inUse > th.bytes && !th.exceeded.Load() {
+				a.updateThreshold(th)
+			}
+		}
+	}
+
+	return unsafe.Add(ptr, headerSize)
+}
+
+func (a *PressureAllocator) Free(ptr unsafe.Pointer) {
+	if ptr == nil {
+		return
+	}
+
+	ptr = unsafe.Add(ptr, -headerSize)
+	size := *((*uintptr)(ptr))
+	a.base.Free(ptr)
+	a.stats.RecordFree(size)
+
+	inUse := a.inUse.Add(-uint64(size))
+	if ths := a.thresholds.Load(); ths != nil {
+		for _, th := range *ths {
+			if inUse <= th.bytes && th.exceeded.Load() {
+				a.updateThreshold(th)
+			}
+		}
+	}
+}
+
+// InUse returns the number of bytes allocated through this allocator.
+func (a *PressureAllocator) InUse() uint64 {
+	return a.inUse.Load()
+}
+
+// OnThreshold registers a callback invoked when usage goes above the given number of bytes and when it drops back.
+// It returns a function that unregisters the callback.
+func (a *PressureAllocator) OnThreshold(bytes uint64, cb Callback) (unregister func()) {
+	th := &threshold{
+		bytes: bytes,
+		cb:    cb,
+	}
+
+	a.mtx.Lock()
+	a.replaceThresholds(func(ths []*threshold) []*threshold {
+		return append(ths, th)
+	})
+	a.mtx.Unlock()
+
+	// Notify right away if the threshold is already exceeded
+	a.updateThreshold(th)
+
+	return func() {
+		a.mtx.Lock()
+		a.replaceThresholds(func(ths []*threshold) []*threshold {
+			for idx := range ths {
+				if ths[idx] == th {
+					return append(ths[:idx], ths[idx+1:]...)
+				}
+			}
+			return ths
+		})
+		a.mtx.Unlock()
+	}
+}
+
+// Stats returns the usage statistics.
+func (a *PressureAllocator) Stats() allocator.Stats {
+	return a.stats.Snapshot()
+}
+
+// updateThreshold brings the state of the threshold in line with the current usage and notifies each transition.
+//
+// Other goroutines keep allocating and freeing while a transition is made, so the usage is checked again after each
+// one. If it no longer holds, the opposite transition is made, so the state always ends up matching the usage.
+// Callbacks are not called with a lock held because they may free memory through this allocator.
+func (a *PressureAllocator) updateThreshold(th *threshold) {
+	for {
+		exceeded := th.exceeded.Load()
+		inUse := a.inUse.Load()
+		if (inUse > th.bytes) == exceeded {
+			return
+		}
+		if !th.exceeded.CompareAndSwap(exceeded, !exceeded) {
+			continue
+		}
+
+		ev := Event{
+			Kind:      ThresholdExceeded,
+			InUse:     inUse,
+			Threshold: th.bytes,
+		}
+		if exceeded {
+			ev.Kind = ThresholdRecovered
+		}
+		th.cb(ev)
+	}
+}
+
+// replaceThresholds must be called with the mutex held. The list is copied so readers never need a lock.
+func (a *PressureAllocator) replaceThresholds(fn func(ths []*threshold) []*threshold) {
+	var ths []*threshold
+
+	if cur := a.thresholds.Load(); cur != nil {
+		ths = append(ths, *cur...)
+	}
+	ths = fn(ths)
+	a.thresholds.Store(&ths)
+}
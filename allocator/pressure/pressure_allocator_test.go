@@ -0,0 +1,151 @@
+package pressure_test
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mxmauro/unmanagedgen/allocator/internal/testalloc"
+	"github.com/mxmauro/unmanagedgen/allocator/pressure"
+)
+
+// -----------------------------------------------------------------------------
+
+func TestPressureThresholds(t *testing.T) {
+	alloc := pressure.New(testalloc.NewHeap())
+
+	events := make([]pressure.Event, 0)
+	unregister := alloc.OnThreshold(1000, func(ev pressure.Event) {
+		events = append(events, ev)
+	})
+
+	ptr1 := alloc.Alloc(600)
+	ptr2 := alloc.Alloc(600)
+	ptr3 := alloc.Alloc(600)
+	alloc.Free(ptr2)
+	alloc.Free(ptr3)
+	if len(events) != 2 || events[0].Kind != pressure.ThresholdExceeded || events[0].InUse != 1200 ||
+		events[1].Kind != pressure.ThresholdRecovered || events[1].InUse != 600 {
+		t.Fatalf("unexpected events [%+v]", events)
+	}
+
+	unregister()
+	alloc.Free(alloc.Alloc(600))
+	alloc.Free(ptr1)
+	if len(events) != 2 || alloc.InUse() != 0 {
+		t.Fatalf("callback invoked after unregistering [%+v]", events)
+	}
+}
+
+func TestPressureThresholdsConcurrent(t *testing.T) {
+	alloc := pressure.New(testalloc.NewHeap())
+
+	var exceeded, recovered atomic.Int64
+	alloc.OnThreshold(1000, func(ev pressure.Event) {
+		if ev.Kind == pressure.ThresholdExceeded {
+			exceeded.Add(1)
+		} else {
+			recovered.Add(1)
+		}
+	})
+
+	// Each goroutine alone stays below the threshold, so it is crossed back and forth depending on the interleaving
+	wg := sync.WaitGroup{}
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for idx := 0; idx < 10000; idx++ {
+				alloc.Free(alloc.Alloc(400))
+			}
+		}()
+	}
+	wg.Wait()
+
+	if alloc.InUse() != 0 || exceeded.Load() != recovered.Load() {
+		t.Fatalf("threshold state does not match usage [exceeded=%v, recovered=%v]", exceeded.Load(), recovered.Load())
+	}
+}
+
+func TestPressureCgroup(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "memory.current"), "500\n")
+	writeFile(t, filepath.Join(root, "memory.max"), "1000\n")
+
+	alloc := pressure.New(testalloc.NewHeap())
+	ch := make(chan pressure.Event, 4)
+	stop, err := alloc.WatchCgroup(pressure.CgroupOptions{
+		Ratio:    0.8,
+		Interval: 5 * time.Millisecond,
+		Root:     root,
+	}, func(ev pressure.Event) {
+		ch <- ev
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+
+	writeFile(t, filepath.Join(root, "memory.current"), "900\n")
+	ev := waitEvent(t, ch)
+	if ev.Kind != pressure.CgroupNearLimit || ev.CgroupUsage != 900 || ev.CgroupLimit != 1000 {
+		t.Fatalf("unexpected event [%+v]", ev)
+	}
+
+	writeFile(t, filepath.Join(root, "memory.current"), "100\n")
+	ev = waitEvent(t, ch)
+	if ev.Kind != pressure.CgroupRecovered {
+		t.Fatalf("unexpected event [%+v]", ev)
+	}
+}
+
+func TestPressureCgroupNoLimit(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "memory.current"), "500\n")
+
+	alloc := pressure.New(testalloc.NewHeap())
+	stop, err := alloc.WatchCgroup(pressure.CgroupOptions{
+		Root: root,
+	}, func(ev pressure.Event) {
+		t.Errorf("unexpected event [%+v]", ev)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stop()
+}
+
+func TestPressureCgroupNotFound(t *testing.T) {
+	alloc := pressure.New(testalloc.NewHeap())
+	_, err := alloc.WatchCgroup(pressure.CgroupOptions{
+		Root: t.TempDir(),
+	}, func(_ pressure.Event) {})
+	if err != pressure.ErrCgroupNotFound {
+		t.Fatalf("unexpected error [err=%v]", err)
+	}
+}
+
+func waitEvent(t *testing.T, ch chan pressure.Event) pressure.Event {
+	select {
+	case ev := <-ch:
+		return ev
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timeout waiting for a cgroup event")
+	}
+	return pressure.Event{}
+}
+
+func writeFile(t *testing.T, name string, data string) {
+	// Write and rename so the watcher never reads a partial file
+	tmpName := name + ".tmp"
+	if err := os.WriteFile(tmpName, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmpName, name); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,7 @@
+// Package profile provides an allocator middleware that records sampled unmanaged allocations in a custom
+// runtime/pprof profile, so live unmanaged memory can be inspected with the standard pprof tooling.
+//
+// Custom pprof profiles only hold counts, so the profile shows the number of live sampled blocks per call site and
+// not the live unmanaged bytes per call site. An estimate of the total live bytes is returned by
+// Profiler.SampledBytes instead.
+package profile
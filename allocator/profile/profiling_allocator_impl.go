@@ -0,0 +1,223 @@
+package profile
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"runtime"
+	"runtime/pprof"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+
+	"github.com/mxmauro/unmanagedgen/allocator"
+)
+
+// -----------------------------------------------------------------------------
+
+// ProfileName is the name of the pprof profile where sampled allocations are recorded.
+const ProfileName = "unmanagedgen.inuse"
+
+const maxStackDepth = 32
+
+var inuseProfile = pprof.NewProfile(ProfileName)
+
+// Frames of these packages are left out of the sampled stacks, so they start at the code that made the allocation
+var (
+	profilePkg   = reflect.TypeOf(Profiler{}).PkgPath()
+	allocatorPkg = reflect.TypeOf(allocator.Stats{}).PkgPath()
+)
+
+// -----------------------------------------------------------------------------
+
+// Profiler is an allocator.Middleware that samples allocations the same way runtime.MemProfileRate does.
+//
+// Sampled blocks are added to the ProfileName profile along with the allocation stack and removed when freed.
+// Custom pprof profiles only hold counts, so go tool pprof shows the number of live sampled blocks by call site,
+// whatever their size, and not bytes. The bytes are only available through SampledBytes, which returns the sum of
+// the weights of the live samples, each weight being the number of bytes the sample stands for.
+type Profiler struct {
+	rate int64
+
+	bytesUntilSample atomic.Int64
+	sampledCount     atomic.Int64
+
+	mtx          sync.Mutex
+	sampled      map[unsafe.Pointer]sampleKey
+	sampledBytes uint64
+}
+
+// The profile is shared by all instances, so the key includes the profiler that owns the block
+type sampleKey struct {
+	owner  *Profiler
+	ptr    unsafe.Pointer
+	weight uint64
+}
+
+// -----------------------------------------------------------------------------
+
+// New creates a new profiling allocator on top of the given one that samples, on average, one allocation every rate
+// bytes. It returns the allocator, which exposes the same optional interfaces as allocator.Chain, so the base
+// allocator keeps its calloc and realloc paths, and the profiler, which reports the sampled bytes.
+func New(base allocator.Allocator, rate int) (allocator.Allocator, *Profiler) {
+	p := NewProfiler(rate)
+	return allocator.Chain(base, p), p
+}
+
+// NewProfiler creates a new profiling middleware, to be combined with others in allocator.Chain. If rate is zero or
+// negative, the current runtime.MemProfileRate is used. A rate of 1 records every allocation.
+func NewProfiler(rate int) *Profiler {
+	if rate <= 0 {
+		rate = runtime.MemProfileRate
+		if rate <= 0 {
+			rate = 512 * 1024
+		}
+	}
+
+	p := Profiler{
+		rate:    int64(rate),
+		sampled: make(map[unsafe.Pointer]sampleKey),
+	}
+	p.bytesUntilSample.Store(p.nextSample())
+	return &p
+}
+
+// Rate returns the average number of bytes between samples.
+func (p *Profiler) Rate() int {
+	return int(p.rate)
+}
+
+// SampledBytes returns an estimate of the live bytes, computed from the sampled blocks that were not freed yet.
+func (p *Profiler) SampledBytes() uint64 {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	return p.sampledBytes
+}
+
+func (p *Profiler) Alloc(size uintptr, next allocator.AllocFunc) unsafe.Pointer {
+	ptr := next(size)
+	if ptr == nil {
+		return nil
+	}
+
+	if p.shouldSample(size) {
+		p.recordSample(ptr, size)
+	} else if p.sampledCount.Load() > 0 {
+		// The address can still hold the sample of a block resized in place or released in bulk
+		p.mtx.Lock()
+		p.removeSample(ptr)
+		p.mtx.Unlock()
+	}
+	return ptr
+}
+
+func (p *Profiler) Free(ptr unsafe.Pointer, next allocator.FreeFunc) {
+	if ptr != nil && p.sampledCount.Load() > 0 {
+		p.mtx.Lock()
+		p.removeSample(ptr)
+		p.mtx.Unlock()
+	}
+
+	next(ptr)
+}
+
+// shouldSample consumes size bytes of the distance to the next sample and returns true if the allocation must be
+// sampled, starting a new distance.
+func (p *Profiler) shouldSample(size uintptr) bool {
+	if p.rate == 1 {
+		return true
+	}
+	for {
+		// Concurrent allocations must not both see the distance run out, so it is only replaced if still current
+		left := p.bytesUntilSample.Load()
+		if left > int64(size) {
+			if p.bytesUntilSample.CompareAndSwap(left, left-int64(size)) {
+				return false
+			}
+		} else if p.bytesUntilSample.CompareAndSwap(left, p.nextSample()) {
+			return true
+		}
+	}
+}
+
+func (p *Profiler) recordSample(ptr unsafe.Pointer, size uintptr) {
+	// On average, a sample is taken every Rate bytes, so a small block stands for Rate bytes
+	key := sampleKey{
+		owner:  p,
+		ptr:    ptr,
+		weight: uint64(max(int64(size), p.rate)),
+	}
+	skip := stackSkip()
+
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	// Allocators that release memory in bulk, like arenas, and blocks resized in place hand out the same address
+	// again without Free being called, so drop the stale sample first
+	p.removeSample(ptr)
+
+	p.sampled[ptr] = key
+	p.sampledBytes += key.weight
+	inuseProfile.Add(key, skip)
+	p.sampledCount.Add(1)
+}
+
+// removeSample removes the profile entry of the block, if it was sampled. The caller must hold the mutex.
+func (p *Profiler) removeSample(ptr unsafe.Pointer) {
+	key, ok := p.sampled[ptr]
+	if !ok {
+		return
+	}
+
+	delete(p.sampled, ptr)
+	p.sampledBytes -= key.weight
+	inuseProfile.Remove(key)
+	p.sampledCount.Add(-1)
+}
+
+func (p *Profiler) nextSample() int64 {
+	if p.rate == 1 {
+		return 1
+	}
+	// Exponentially distributed distance, like the runtime does, so samples do not correlate with allocation sizes
+	next := -math.Log(1-rand.Float64()) * float64(p.rate)
+	if next > math.MaxInt32 {
+		return math.MaxInt32
+	}
+	return int64(next) + 1
+}
+
+// stackSkip returns the skip to pass to pprof.Profile.Add, when called by the same function, so the stack starts at
+// the first frame that does not belong to this package or to the chain in the allocator package.
+func stackSkip() int {
+	var pcs [maxStackDepth]uintptr
+
+	// Skip runtime.Callers and stackSkip
+	n := runtime.Callers(2, pcs[:])
+	frames := runtime.CallersFrames(pcs[:n])
+	// With a skip of zero, the stack starts at Add itself
+	skip := 1
+	for {
+		frame, more := frames.Next()
+		pkg := funcPackage(frame.Function)
+		if pkg != profilePkg && pkg != allocatorPkg {
+			return skip
+		}
+		skip += 1
+		if !more {
+			return skip
+		}
+	}
+}
+
+// funcPackage returns the package path of a fully qualified function name, like "example.com/pkg.(*T).Method".
+func funcPackage(name string) string {
+	slash := strings.LastIndexByte(name, '/')
+	dot := strings.IndexByte(name[slash+1:], '.')
+	if dot < 0 {
+		return name
+	}
+	return name[:slash+1+dot]
+}
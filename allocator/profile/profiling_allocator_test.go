@@ -0,0 +1,168 @@
+package profile_test
+
+import (
+	"bytes"
+	"runtime/pprof"
+	"strings"
+	"sync"
+	"testing"
+	"unsafe"
+
+	"github.com/mxmauro/unmanagedgen/allocator"
+	"github.com/mxmauro/unmanagedgen/allocator/arena"
+	"github.com/mxmauro/unmanagedgen/allocator/internal/testalloc"
+	"github.com/mxmauro/unmanagedgen/allocator/profile"
+)
+
+// -----------------------------------------------------------------------------
+
+// reallocHeapAllocator moves blocks when they grow and resizes them in place when they shrink.
+type reallocHeapAllocator struct {
+	*testalloc.HeapAllocator
+}
+
+// -----------------------------------------------------------------------------
+
+func TestProfilingAllocator(t *testing.T) {
+	alloc, p := profile.New(testalloc.NewHeap(), 1)
+
+	prof := pprof.Lookup(profile.ProfileName)
+	if prof == nil {
+		t.Fatalf("profile %v is not registered", profile.ProfileName)
+	}
+	before := prof.Count()
+
+	ptrs := make([]unsafe.Pointer, 0)
+	for idx := 0; idx < 10; idx++ {
+		ptrs = append(ptrs, profiledAlloc(alloc))
+	}
+	// Each sampled block is a single entry, whatever its size
+	ptrs = append(ptrs, alloc.Alloc(1024*1024))
+	if prof.Count()-before != 11 {
+		t.Fatalf("unexpected number of profile entries [got=%v]", prof.Count()-before)
+	}
+	if p.SampledBytes() != 10*4+1024*1024 {
+		t.Fatalf("unexpected sampled bytes [got=%v]", p.SampledBytes())
+	}
+
+	var buf bytes.Buffer
+	if err := prof.WriteTo(&buf, 1); err != nil {
+		t.Fatal(err)
+	}
+	// The stacks start at the caller of Alloc, leaving out the profiler and the chain
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if strings.HasPrefix(line, "#") && (strings.Contains(line, "/allocator.") ||
+			strings.Contains(line, "/allocator/profile.")) {
+			t.Fatalf("profile contains allocator frames:\n%v", buf.String())
+		}
+	}
+	if !strings.Contains(buf.String(), "profiledAlloc") {
+		t.Fatalf("profile does not contain the allocation site:\n%v", buf.String())
+	}
+
+	for _, ptr := range ptrs {
+		alloc.Free(ptr)
+	}
+	if prof.Count() != before || p.SampledBytes() != 0 {
+		t.Fatalf("freed blocks are still in the profile")
+	}
+}
+
+func TestProfilingAllocatorReusedAddress(t *testing.T) {
+	backing := arena.New(testalloc.NewHeap(), 0)
+	alloc, p := profile.New(backing, 1)
+
+	prof := pprof.Lookup(profile.ProfileName)
+	before := prof.Count()
+
+	// The arena hands out the same address again after a reset, without the first block being freed
+	ptr := profiledAlloc(alloc)
+	backing.Reset()
+	if profiledAlloc(alloc) != ptr {
+		t.Fatalf("arena did not reuse the address")
+	}
+	if prof.Count()-before != 1 || p.SampledBytes() != 4 {
+		t.Fatalf("unexpected number of profile entries [got=%v]", prof.Count()-before)
+	}
+
+	alloc.Free(ptr)
+	if prof.Count() != before {
+		t.Fatalf("freed blocks are still in the profile")
+	}
+	sp, ok := alloc.(allocator.StatsProvider)
+	if !ok || sp.Stats().Allocs != 2 || sp.Stats().Allocs != backing.Stats().Allocs {
+		t.Fatalf("stats of the base allocator not forwarded")
+	}
+	backing.Release()
+}
+
+func TestProfilingAllocatorOptionalInterfaces(t *testing.T) {
+	base := &reallocHeapAllocator{
+		HeapAllocator: testalloc.NewHeap(),
+	}
+	alloc, p := profile.New(base, 1)
+
+	prof := pprof.Lookup(profile.ProfileName)
+	before := prof.Count()
+
+	ra, ok := alloc.(allocator.Reallocator)
+	if !ok {
+		t.Fatalf("profiling allocator does not implement Reallocator")
+	}
+	if _, ok = alloc.(allocator.ZeroAllocator); !ok {
+		t.Fatalf("profiling allocator does not implement ZeroAllocator")
+	}
+
+	ptr := allocator.AllocZeroed(alloc, 16)
+	ptr = ra.Realloc(ptr, 64)
+	if prof.Count()-before != 1 || p.SampledBytes() != 64 {
+		t.Fatalf("moved block not sampled [entries=%v, bytes=%v]", prof.Count()-before, p.SampledBytes())
+	}
+	// Resizing in place hands out the same address again
+	ptr = ra.Realloc(ptr, 32)
+	if prof.Count()-before != 1 || p.SampledBytes() != 32 {
+		t.Fatalf("block resized in place not sampled [entries=%v, bytes=%v]", prof.Count()-before, p.SampledBytes())
+	}
+
+	alloc.Free(ptr)
+	if prof.Count() != before || p.SampledBytes() != 0 || base.Live() != 0 {
+		t.Fatalf("freed blocks are still in the profile")
+	}
+}
+
+func TestProfilingAllocatorConcurrent(t *testing.T) {
+	base := testalloc.NewHeap()
+	alloc, p := profile.New(base, 64)
+
+	wg := sync.WaitGroup{}
+	for worker := 0; worker < 8; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for idx := 0; idx < 1000; idx++ {
+				alloc.Free(alloc.Alloc(16))
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Samples taken by concurrent allocations must all be dropped when their blocks are freed
+	if p.SampledBytes() != 0 || base.Live() != 0 {
+		t.Fatalf("blocks still sampled after being freed [bytes=%v]", p.SampledBytes())
+	}
+}
+
+func profiledAlloc(alloc allocator.Allocator) unsafe.Pointer {
+	return alloc.Alloc(4)
+}
+
+func (a *reallocHeapAllocator) Realloc(ptr unsafe.Pointer, size uintptr) unsafe.Pointer {
+	if size <= uintptr(len(a.Block(ptr))) {
+		return ptr
+	}
+	newPtr := a.Alloc(size)
+	copy(a.Block(newPtr), a.Block(ptr))
+	a.Free(ptr)
+	return newPtr
+}
@@ -0,0 +1,20 @@
+// Package publish exports allocator statistics to monitoring systems.
+package publish
+
+import (
+	"expvar"
+
+	"github.com/mxmauro/unmanagedgen/allocator"
+)
+
+// -----------------------------------------------------------------------------
+
+// Expvar publishes the statistics of the given allocator as an expvar variable with the given name. The variable
+// holds an object keyed by the allocator.Metric* names and is evaluated each time it is read.
+//
+// Like expvar.Publish, it panics if the name is already in use.
+func Expvar(name string, sp allocator.StatsProvider) {
+	expvar.Publish(name, expvar.Func(func() any {
+		return sp.Stats().Metrics()
+	}))
+}
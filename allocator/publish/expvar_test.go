@@ -0,0 +1,61 @@
+package publish_test
+
+import (
+	"encoding/json"
+	"expvar"
+	"testing"
+
+	"github.com/mxmauro/unmanagedgen/allocator"
+	"github.com/mxmauro/unmanagedgen/allocator/publish"
+)
+
+// -----------------------------------------------------------------------------
+
+type statsProvider struct {
+	stats allocator.StatsCounter
+}
+
+// -----------------------------------------------------------------------------
+
+func TestExpvar(t *testing.T) {
+	sp := &statsProvider{}
+	sp.stats.RecordAlloc(100)
+	sp.stats.RecordAlloc(200)
+	sp.stats.RecordFree(100)
+
+	publish.Expvar("test.allocator", sp)
+
+	m := readExpvar(t, "test.allocator")
+	if m[allocator.MetricBytesInUse] != float64(200) || m[allocator.MetricHighWaterMark] != float64(300) ||
+		m[allocator.MetricAllocs] != float64(2) || m[allocator.MetricFrees] != float64(1) {
+		t.Fatalf("unexpected values [%v]", m)
+	}
+	bySize, ok := m[allocator.MetricAllocsBySize].(map[string]any)
+	if !ok || len(bySize) != 2 {
+		t.Fatalf("unexpected size histogram [%v]", m[allocator.MetricAllocsBySize])
+	}
+
+	// The variable is evaluated each time it is read
+	sp.stats.RecordFree(200)
+	m = readExpvar(t, "test.allocator")
+	if m[allocator.MetricBytesInUse] != float64(0) || m[allocator.MetricFrees] != float64(2) {
+		t.Fatalf("values not refreshed [%v]", m)
+	}
+}
+
+func readExpvar(t *testing.T, name string) map[string]any {
+	v := expvar.Get(name)
+	if v == nil {
+		t.Fatalf("variable %v not published", name)
+	}
+
+	var m map[string]any
+	if err := json.Unmarshal([]byte(v.String()), &m); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func (sp *statsProvider) Stats() allocator.Stats {
+	return sp.stats.Snapshot()
+}
@@ -0,0 +1,6 @@
+// Package secure provides an allocator for secrets such as credentials and keys. Blocks are locked in RAM so they
+// are never swapped, excluded from core dumps where supported, wiped when released and can be made read-only while
+// they are not being modified.
+//
+// It is available on Linux and macOS.
+package secure
@@ -0,0 +1,10 @@
+//go:build darwin
+
+package secure
+
+// -----------------------------------------------------------------------------
+
+// macOS has no way to exclude a range from core dumps.
+func excludeFromCoreDump(_ []byte) error {
+	return nil
+}
@@ -0,0 +1,18 @@
+//go:build linux
+
+package secure
+
+import (
+	"syscall"
+)
+
+// -----------------------------------------------------------------------------
+
+// The syscall package does not define MADV_DONTDUMP. Its value is the same on every architecture.
+const madvDontDump = 0x10
+
+// -----------------------------------------------------------------------------
+
+func excludeFromCoreDump(b []byte) error {
+	return syscall.Madvise(b, madvDontDump)
+}
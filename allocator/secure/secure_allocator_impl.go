@@ -0,0 +1,233 @@
+//go:build linux || darwin
+
+package secure
+
+import (
+	"errors"
+	"os"
+	"runtime"
+	"sync"
+	"syscall"
+	"unsafe"
+
+	"github.com/mxmauro/unmanagedgen/allocator"
+)
+
+// -----------------------------------------------------------------------------
+
+const maxInt = int(^uint(0) >> 1)
+
+// -----------------------------------------------------------------------------
+
+// ErrUnknownBlock is returned by Protect and Unprotect when the pointer was not returned by Alloc or was already freed.
+var ErrUnknownBlock = errors.New("pointer not allocated by this allocator")
+
+// -----------------------------------------------------------------------------
+
+// Options configures a SecureAllocator.
+type Options struct {
+	// AllowUnlocked lets Alloc succeed when the memory cannot be locked, for example, because RLIMIT_MEMLOCK is too
+	// low. By default, such allocations fail.
+	AllowUnlocked bool
+
+	// AllowDumpable lets Alloc succeed when the memory cannot be excluded from core dumps. By default, such
+	// allocations fail.
+	AllowDumpable bool
+}
+
+// SecureAllocator gives each block its own set of pages, locked in RAM and excluded from core dumps. Blocks are
+// wiped before their pages are returned to the OS.
+//
+// Blocks can be made read-only with Protect or ProtectAll. Writing to a protected block, including calling Free or
+// a setter of a generated struct stored in one, faults. Use Update to modify protected data.
+type SecureAllocator struct {
+	opts     Options
+	pageSize uintptr
+	stats    allocator.StatsCounter
+
+	mtx    sync.Mutex
+	blocks map[unsafe.Pointer]blockInfo
+}
+
+// The block sizes are kept outside the blocks, so an invalid pointer is detected before its memory is touched.
+type blockInfo struct {
+	mapLen   uintptr
+	size     uintptr
+	readOnly bool
+}
+
+// -----------------------------------------------------------------------------
+
+// New creates a new secure allocator.
+func New(opts Options) *SecureAllocator {
+	return &SecureAllocator{
+		opts:     opts,
+		pageSize: uintptr(os.Getpagesize()),
+		blocks:   make(map[unsafe.Pointer]blockInfo),
+	}
+}
+
+func (a *SecureAllocator) Alloc(size uintptr) unsafe.Pointer {
+	total, overflow := allocator.AddUintptr(max(size, 1), a.pageSize-1)
+	if overflow {
+		return nil
+	}
+	mapLen := total &^ (a.pageSize - 1)
+	if mapLen > uintptr(maxInt) {
+		return nil
+	}
+
+	b, err := syscall.Mmap(-1, 0, int(mapLen), syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_ANON|syscall.MAP_PRIVATE)
+	if err != nil {
+		return nil
+	}
+	if err = syscall.Mlock(b); err != nil && !a.opts.AllowUnlocked {
+		_ = syscall.Munmap(b)
+		return nil
+	}
+	if err = excludeFromCoreDump(b); err != nil && !a.opts.AllowDumpable {
+		_ = syscall.Munlock(b)
+		_ = syscall.Munmap(b)
+		return nil
+	}
+
+	ptr := unsafe.Pointer(unsafe.SliceData(b))
+
+	a.mtx.Lock()
+	a.blocks[ptr] = blockInfo{
+		mapLen: mapLen,
+		size:   size,
+	}
+	a.mtx.Unlock()
+
+	a.stats.RecordAlloc(size)
+	return ptr
+}
+
+func (a *SecureAllocator) Free(ptr unsafe.Pointer) {
+	if ptr == nil {
+		return
+	}
+
+	a.mtx.Lock()
+	info, found := a.blocks[ptr]
+	delete(a.blocks, ptr)
+	a.mtx.Unlock()
+	if !found {
+		panic("SecureAllocator::free of foreign pointer or double free detected")
+	}
+
+	b := unsafe.Slice((*byte)(ptr), info.mapLen)
+
+	if err := syscall.Mprotect(b, syscall.PROT_READ|syscall.PROT_WRITE); err != nil {
+		panic("SecureAllocator: unable to unprotect memory [err=" + err.Error() + "]")
+	}
+	wipe(b)
+	_ = syscall.Munlock(b)
+	if err := syscall.Munmap(b); err != nil {
+		panic("SecureAllocator: unable to unmap memory [err=" + err.Error() + "]")
+	}
+
+	a.stats.RecordFree(info.size)
+}
+
+// Protect makes the block read-only. It returns ErrUnknownBlock if ptr is not a live block of this allocator.
+func (a *SecureAllocator) Protect(ptr unsafe.Pointer) error {
+	return a.mprotectBlock(ptr, true)
+}
+
+// Unprotect makes the block writable again. It returns ErrUnknownBlock if ptr is not a live block of this allocator.
+func (a *SecureAllocator) Unprotect(ptr unsafe.Pointer) error {
+	return a.mprotectBlock(ptr, false)
+}
+
+// ProtectAll makes every live block read-only. On failure, every block keeps its previous protection.
+func (a *SecureAllocator) ProtectAll() error {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	return a.mprotectAll(true)
+}
+
+// UnprotectAll makes every live block writable again. On failure, every block keeps its previous protection.
+func (a *SecureAllocator) UnprotectAll() error {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	return a.mprotectAll(false)
+}
+
+// Update makes all blocks writable, runs fn, and makes them read-only again, including the ones allocated by fn. If
+// the blocks cannot be made writable, fn is not run and the blocks are left as they were.
+func (a *SecureAllocator) Update(fn func()) error {
+	if err := a.UnprotectAll(); err != nil {
+		return err
+	}
+	defer func() {
+		_ = a.ProtectAll()
+	}()
+	fn()
+	return nil
+}
+
+// Stats returns the usage statistics.
+func (a *SecureAllocator) Stats() allocator.Stats {
+	return a.stats.Snapshot()
+}
+
+// mprotectAll changes the protection of every live block. If a block cannot be changed, the ones already changed are
+// restored, so all blocks are left as they were before the call, and the error is returned. If a block cannot be
+// restored either, the errors are joined and that block keeps the new protection. Must be called with the mutex
+// held.
+func (a *SecureAllocator) mprotectAll(readOnly bool) error {
+	changed := make([]unsafe.Pointer, 0, len(a.blocks))
+	for ptr, info := range a.blocks {
+		if info.readOnly == readOnly {
+			continue
+		}
+		if err := a.mprotect(ptr, info, readOnly); err != nil {
+			for _, ptr = range changed {
+				if err2 := a.mprotect(ptr, a.blocks[ptr], !readOnly); err2 != nil {
+					err = errors.Join(err, err2)
+				}
+			}
+			return err
+		}
+		changed = append(changed, ptr)
+	}
+	return nil
+}
+
+func (a *SecureAllocator) mprotectBlock(ptr unsafe.Pointer, readOnly bool) error {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	info, found := a.blocks[ptr]
+	if !found {
+		return ErrUnknownBlock
+	}
+	return a.mprotect(ptr, info, readOnly)
+}
+
+// mprotect changes the protection of a block and keeps track of it. Must be called with the mutex held.
+func (a *SecureAllocator) mprotect(ptr unsafe.Pointer, info blockInfo, readOnly bool) error {
+	prot := syscall.PROT_READ | syscall.PROT_WRITE
+	if readOnly {
+		prot = syscall.PROT_READ
+	}
+	if err := syscall.Mprotect(unsafe.Slice((*byte)(ptr), info.mapLen), prot); err != nil {
+		return err
+	}
+	info.readOnly = readOnly
+	a.blocks[ptr] = info
+	return nil
+}
+
+// wipe zeroes the memory. It is never inlined and the buffer is kept alive until it returns, so the compiler cannot
+// treat the stores as dead and drop them.
+//
+//go:noinline
+func wipe(b []byte) {
+	clear(b)
+	runtime.KeepAlive(b)
+}
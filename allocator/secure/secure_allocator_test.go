@@ -0,0 +1,104 @@
+//go:build linux || darwin
+
+package secure_test
+
+import (
+	"errors"
+	"runtime/debug"
+	"testing"
+	"unsafe"
+
+	"github.com/mxmauro/unmanagedgen/allocator/secure"
+)
+
+// -----------------------------------------------------------------------------
+
+func TestSecureAllocator(t *testing.T) {
+	alloc := secure.New(secure.Options{})
+
+	ptr := alloc.Alloc(64)
+	if ptr == nil {
+		t.Skip("unable to lock memory")
+	}
+	secret := unsafe.Slice((*byte)(ptr), 64)
+	copy(secret, "password")
+
+	if err := alloc.ProtectAll(); err != nil {
+		t.Fatal(err)
+	}
+	if !faults(func() {
+		secret[0] = 'P'
+	}) {
+		t.Fatalf("write to a protected block did not fault")
+	}
+	if string(secret[:8]) != "password" {
+		t.Fatalf("protected block cannot be read")
+	}
+
+	err := alloc.Update(func() {
+		secret[0] = 'P'
+	})
+	if err != nil || secret[0] != 'P' {
+		t.Fatalf("update failed [err=%v]", err)
+	}
+	if !faults(func() {
+		secret[0] = 'p'
+	}) {
+		t.Fatalf("block not protected again after update")
+	}
+
+	alloc.Free(ptr)
+	if alloc.Stats().BytesInUse != 0 {
+		t.Fatalf("Usage is not zero! [%v]", alloc.Stats().BytesInUse)
+	}
+}
+
+func TestSecureAllocatorInvalidPointer(t *testing.T) {
+	alloc := secure.New(secure.Options{})
+
+	ptr := alloc.Alloc(64)
+	if ptr == nil {
+		t.Skip("unable to lock memory")
+	}
+	var local [64]byte
+
+	if err := alloc.Protect(unsafe.Pointer(&local[0])); !errors.Is(err, secure.ErrUnknownBlock) {
+		t.Fatalf("protect of a foreign pointer not detected [err=%v]", err)
+	}
+	if !panics(func() {
+		alloc.Free(unsafe.Pointer(&local[0]))
+	}) {
+		t.Fatalf("free of a foreign pointer not detected")
+	}
+
+	alloc.Free(ptr)
+	if err := alloc.Unprotect(ptr); !errors.Is(err, secure.ErrUnknownBlock) {
+		t.Fatalf("unprotect of a freed block not detected [err=%v]", err)
+	}
+	if !panics(func() {
+		alloc.Free(ptr)
+	}) {
+		t.Fatalf("double free not detected")
+	}
+}
+
+func faults(fn func()) (faulted bool) {
+	defer func() {
+		faulted = recover() != nil
+	}()
+
+	old := debug.SetPanicOnFault(true)
+	defer debug.SetPanicOnFault(old)
+
+	fn()
+	return false
+}
+
+func panics(fn func()) (panicked bool) {
+	defer func() {
+		panicked = recover() != nil
+	}()
+
+	fn()
+	return false
+}
@@ -0,0 +1,3 @@
+// Package sharded provides an allocator that spreads concurrent requests over several shards, each one with its own
+// free-list cache, to avoid contention on a single backing allocator.
+package sharded
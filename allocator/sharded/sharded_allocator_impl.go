@@ -0,0 +1,226 @@
+package sharded
+
+import (
+	"math/bits"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+
+	"github.com/mxmauro/unmanagedgen/allocator"
+)
+
+// -----------------------------------------------------------------------------
+
+const (
+	// Every block is preceded by a header with its requested size and size class. 16 bytes keep the user data
+	// aligned.
+	headerSize = 16
+
+	minClassShift = 4  // 16 bytes
+	maxClassShift = 15 // 32 KiB
+	classesCount  = maxClassShift - minClassShift + 1
+
+	// Blocks bigger than the largest class are not cached
+	largeClass = ^uintptr(0)
+
+	DefaultCacheSize = 64
+
+	cacheLineSize = 128
+)
+
+// -----------------------------------------------------------------------------
+
+// Options configures a ShardedAllocator.
+type Options struct {
+	// Shards is the number of shards. Defaults to GOMAXPROCS.
+	Shards int
+
+	// CacheSize is the maximum number of free blocks each shard keeps per size class. Defaults to 64.
+	CacheSize int
+}
+
+// ShardedAllocator caches freed blocks in per-shard free lists, one per power-of-two size class up to 32 KiB, on top
+// of a shared backing allocator that must be safe for concurrent use.
+//
+// Goroutines are mapped to shards through a sync.Pool, which keeps per-P storage, so goroutines running on the same
+// P tend to use the same shard. Blocks carry their size class in a header, so they can be freed on any shard.
+type ShardedAllocator struct {
+	backing   allocator.Allocator
+	cacheSize int
+	shards    []shard
+	hints     sync.Pool
+	nextHint  atomic.Uint64
+}
+
+type shard struct {
+	mtx   sync.Mutex
+	heads [classesCount]unsafe.Pointer
+	count [classesCount]int
+
+	allocs     atomic.Uint64
+	frees      atomic.Uint64
+	allocBytes atomic.Uint64
+	freeBytes  atomic.Uint64
+
+	_ [cacheLineSize]byte
+}
+
+type shardHint struct {
+	idx int
+}
+
+type blockHeader struct {
+	size  uintptr
+	class uintptr
+}
+
+// -----------------------------------------------------------------------------
+
+// New creates a new sharded allocator on top of the given one.
+func New(backing allocator.Allocator, opts Options) *ShardedAllocator {
+	if opts.Shards <= 0 {
+		opts.Shards = runtime.GOMAXPROCS(0)
+	}
+	if opts.CacheSize <= 0 {
+		opts.CacheSize = DefaultCacheSize
+	}
+
+	a := &ShardedAllocator{
+		backing:   backing,
+		cacheSize: opts.CacheSize,
+		shards:    make([]shard, opts.Shards),
+	}
+	a.hints.New = func() any {
+		return &shardHint{
+			idx: int(a.nextHint.Add(1) % uint64(len(a.shards))),
+		}
+	}
+	return a
+}
+
+func (a *ShardedAllocator) Alloc(size uintptr) unsafe.Pointer {
+	var ptr unsafe.Pointer
+
+	if _, overflow := allocator.AddUintptr(size, headerSize); overflow {
+		return nil
+	}
+	class := classOf(size)
+
+	hint := a.hints.Get().(*shardHint)
+	s := &a.shards[hint.idx]
+	a.hints.Put(hint)
+
+	if class != largeClass {
+		s.mtx.Lock()
+		ptr = s.heads[class]
+		if ptr != nil {
+			s.heads[class] = *((*unsafe.Pointer)(unsafe.Add(ptr, headerSize)))
+			s.count[class] -= 1
+		}
+		s.mtx.Unlock()
+	}
+
+	if ptr == nil {
+		blockSize := size
+		if class != largeClass {
+			blockSize = uintptr(1) << (class + minClassShift)
+		}
+		ptr = a.backing.Alloc(blockSize + headerSize)
+		if ptr == nil {
+			return nil
+		}
+	}
+
+	hdr := (*blockHeader)(ptr)
+	hdr.size = size
+	hdr.class = class
+
+	s.allocs.Add(1)
+	s.allocBytes.Add(uint64(size))
+	return unsafe.Add(ptr, headerSize)
+}
+
+func (a *ShardedAllocator) Free(ptr unsafe.Pointer) {
+	if ptr == nil {
+		return
+	}
+
+	ptr = unsafe.Add(ptr, -headerSize)
+	hdr := (*blockHeader)(ptr)
+	size := hdr.size
+	class := hdr.class
+
+	hint := a.hints.Get().(*shardHint)
+	s := &a.shards[hint.idx]
+	a.hints.Put(hint)
+
+	s.frees.Add(1)
+	s.freeBytes.Add(uint64(size))
+
+	if class != largeClass {
+		s.mtx.Lock()
+		if s.count[class] < a.cacheSize {
+			// Link the block using its user area, the header is rewritten when it is reused
+			*((*unsafe.Pointer)(unsafe.Add(ptr, headerSize))) = s.heads[class]
+			s.heads[class] = ptr
+			s.count[class] += 1
+			ptr = nil
+		}
+		s.mtx.Unlock()
+	}
+
+	if ptr != nil {
+		a.backing.Free(ptr)
+	}
+}
+
+// Flush returns every cached block to the backing allocator.
+func (a *ShardedAllocator) Flush() {
+	for idx := range a.shards {
+		s := &a.shards[idx]
+
+		s.mtx.Lock()
+		heads := s.heads
+		s.heads = [classesCount]unsafe.Pointer{}
+		s.count = [classesCount]int{}
+		s.mtx.Unlock()
+
+		for _, ptr := range heads {
+			for ptr != nil {
+				next := *((*unsafe.Pointer)(unsafe.Add(ptr, headerSize)))
+				a.backing.Free(ptr)
+				ptr = next
+			}
+		}
+	}
+}
+
+// Stats returns the usage statistics aggregated from all shards. The high-water mark and the size histogram are not
+// tracked, to keep shards independent.
+func (a *ShardedAllocator) Stats() allocator.Stats {
+	var st allocator.Stats
+	var allocBytes, freeBytes uint64
+
+	for idx := range a.shards {
+		s := &a.shards[idx]
+		st.Allocs += s.allocs.Load()
+		st.Frees += s.frees.Load()
+		allocBytes += s.allocBytes.Load()
+		freeBytes += s.freeBytes.Load()
+	}
+	if allocBytes > freeBytes {
+		st.BytesInUse = allocBytes - freeBytes
+	}
+	return st
+}
+
+func classOf(size uintptr) uintptr {
+	if size > uintptr(1)<<maxClassShift {
+		return largeClass
+	}
+	if size <= uintptr(1)<<minClassShift {
+		return 0
+	}
+	return uintptr(bits.Len64(uint64(size-1))) - minClassShift
+}
@@ -0,0 +1,85 @@
+package sharded_test
+
+import (
+	"sync"
+	"testing"
+	"unsafe"
+
+	"github.com/mxmauro/unmanagedgen/allocator/internal/testalloc"
+	"github.com/mxmauro/unmanagedgen/allocator/sharded"
+)
+
+// -----------------------------------------------------------------------------
+
+func TestShardedAllocatorReuse(t *testing.T) {
+	base := testalloc.NewHeap()
+	alloc := sharded.New(base, sharded.Options{
+		Shards: 1,
+	})
+
+	ptr := alloc.Alloc(100)
+	alloc.Free(ptr)
+	if alloc.Alloc(120) != ptr {
+		t.Fatalf("cached block was not reused for the same size class")
+	}
+	alloc.Free(ptr)
+
+	large := alloc.Alloc(1024 * 1024)
+	alloc.Free(large)
+	if base.Live() != 1 {
+		t.Fatalf("large block was cached [live=%v]", base.Live())
+	}
+
+	alloc.Flush()
+	if base.Live() != 0 {
+		t.Fatalf("cached blocks not released [live=%v]", base.Live())
+	}
+}
+
+func TestShardedAllocatorCrossShardFree(t *testing.T) {
+	const workers = 8
+	const blocksPerWorker = 2000
+
+	base := testalloc.NewHeap()
+	alloc := sharded.New(base, sharded.Options{
+		Shards:    4,
+		CacheSize: 16,
+	})
+
+	// Each worker allocates blocks and hands them to the next one to free
+	ch := make([]chan unsafe.Pointer, workers)
+	for idx := range ch {
+		ch[idx] = make(chan unsafe.Pointer, blocksPerWorker)
+	}
+
+	wg := sync.WaitGroup{}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+
+			for idx := 0; idx < blocksPerWorker; idx++ {
+				size := uintptr(8 + (idx*37)%5000)
+				ptr := alloc.Alloc(size)
+				buf := unsafe.Slice((*byte)(ptr), size)
+				for idx2 := range buf {
+					buf[idx2] = byte(w)
+				}
+				ch[(w+1)%workers] <- ptr
+			}
+			for idx := 0; idx < blocksPerWorker; idx++ {
+				alloc.Free(<-ch[w])
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	st := alloc.Stats()
+	if st.BytesInUse != 0 || st.Allocs != workers*blocksPerWorker || st.Frees != st.Allocs {
+		t.Fatalf("unexpected stats [%+v]", st)
+	}
+	alloc.Flush()
+	if base.Live() != 0 {
+		t.Fatalf("cached blocks not released [live=%v]", base.Live())
+	}
+}
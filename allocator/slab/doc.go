@@ -0,0 +1,3 @@
+// Package slab provides a size-class slab allocator for small fixed-size objects that sits on top of any other
+// allocator.Allocator.
+package slab
@@ -0,0 +1,39 @@
+//go:build cgo
+
+package slab_test
+
+import (
+	"testing"
+	"unsafe"
+
+	"github.com/mxmauro/unmanagedgen/allocator/c"
+	"github.com/mxmauro/unmanagedgen/allocator/slab"
+)
+
+// -----------------------------------------------------------------------------
+
+func TestSlabAllocatorRelease(t *testing.T) {
+	backing := c.NewWithDebug()
+	alloc := slab.New(backing)
+
+	// Fill several slabs of a few size classes, so some chunks end up pooled and others cached when freed
+	ptrs := make([]unsafe.Pointer, 0)
+	for idx := 0; idx < 5000; idx++ {
+		ptrs = append(ptrs, alloc.Alloc(uintptr(32+(idx%4)*200)))
+	}
+	ptrs = append(ptrs, alloc.Alloc(64*1024))
+
+	// Blocks still in use survive a Release
+	alloc.Release()
+	for _, ptr := range ptrs {
+		alloc.Free(ptr)
+	}
+	if backing.Usage() == 0 {
+		t.Fatalf("chunks were given back before Release")
+	}
+
+	alloc.Release()
+	if backing.Usage() != 0 {
+		t.Fatalf("Usage is not zero after Release [%v]", backing.Usage())
+	}
+}
@@ -0,0 +1,312 @@
+package slab
+
+import (
+	"sync"
+	"unsafe"
+
+	"github.com/mxmauro/unmanagedgen/allocator"
+)
+
+// -----------------------------------------------------------------------------
+
+const (
+	// Every block is preceded by a header that links it to its slab (or flags it as a large block)
+	blockHeaderSize = unsafe.Sizeof(blockHeader{})
+
+	// Slab metadata is stored at the beginning of the chunk itself so the Go GC never sees it
+	slabHeaderSize = 64
+	chunkSize      = 64 * 1024
+
+	minBlockSize  = 32
+	maxBlockSize  = 8 * 1024
+	maxFreeChunks = 16
+)
+
+var classSizes []uintptr
+var classBySize [maxBlockSize/16 + 1]uint8
+
+// -----------------------------------------------------------------------------
+
+// SlabAllocator serves small blocks from 64 KiB chunks obtained from a backing allocator.
+//
+// Each chunk (a slab) is dedicated to a size class and keeps its own free list. Size classes are spaced 16 bytes
+// apart up to 128 bytes and four per power of two afterward, up to 8 KiB. Each size class keeps one empty slab
+// cached, so a workload that allocates and frees a single block over and over does not move a chunk in and out of
+// the class every time. Other empty slabs are pooled and reused by any size class. Larger blocks are passed directly
+// to the backing allocator.
+type SlabAllocator struct {
+	backing allocator.Allocator
+	classes []sizeClass
+	stats   allocator.StatsCounter
+
+	chunksMtx       sync.Mutex
+	freeChunks      *slab
+	freeChunksCount int
+}
+
+type sizeClass struct {
+	mtx       sync.Mutex
+	blockSize uintptr
+	partial   *slab
+	empty     *slab // not in the partial list
+}
+
+type slab struct {
+	next     *slab
+	prev     *slab
+	freeList unsafe.Pointer
+	bump     uintptr
+	used     int
+	capacity int
+	class    int
+}
+
+type blockHeader struct {
+	slab *slab   // nil if the block was allocated directly from the backing allocator
+	size uintptr // block size
+}
+
+// -----------------------------------------------------------------------------
+
+func init() {
+	if unsafe.Sizeof(slab{}) > slabHeaderSize {
+		panic("slab: slab header too large")
+	}
+
+	classSizes = make([]uintptr, 0)
+	for size := uintptr(minBlockSize); size <= 128; size += 16 {
+		classSizes = append(classSizes, size)
+	}
+	for pow := uintptr(128); pow < maxBlockSize; pow *= 2 {
+		for step := uintptr(1); step <= 4; step++ {
+			classSizes = append(classSizes, pow+step*pow/4)
+		}
+	}
+
+	classIdx := 0
+	for idx := range classBySize {
+		for uintptr(idx)*16 > classSizes[classIdx] {
+			classIdx++
+		}
+		classBySize[idx] = uint8(classIdx)
+	}
+}
+
+// New creates a new slab allocator on top of the provided backing allocator.
+func New(backing allocator.Allocator) *SlabAllocator {
+	a := SlabAllocator{
+		backing: backing,
+		classes: make([]sizeClass, len(classSizes)),
+	}
+	for idx := range a.classes {
+		a.classes[idx].blockSize = classSizes[idx]
+	}
+	return &a
+}
+
+func (a *SlabAllocator) Alloc(size uintptr) unsafe.Pointer {
+	total, overflow := allocator.AddUintptr(size, blockHeaderSize)
+	if overflow {
+		return nil
+	}
+
+	if total > maxBlockSize {
+		ptr := a.backing.Alloc(total)
+		if ptr == nil {
+			return nil
+		}
+
+		hdr := (*blockHeader)(ptr)
+		hdr.slab = nil
+		hdr.size = total
+		a.stats.RecordAlloc(total)
+		return unsafe.Add(ptr, blockHeaderSize)
+	}
+
+	return a.allocSmall(int(classBySize[(total+15)/16]))
+}
+
+func (a *SlabAllocator) Free(ptr unsafe.Pointer) {
+	if ptr == nil {
+		return
+	}
+
+	hdr := (*blockHeader)(unsafe.Add(ptr, -int(blockHeaderSize)))
+	a.stats.RecordFree(hdr.size)
+	if hdr.slab == nil {
+		a.backing.Free(unsafe.Pointer(hdr))
+		return
+	}
+
+	s := hdr.slab
+	cls := &a.classes[s.class]
+
+	cls.mtx.Lock()
+
+	if s.used == s.capacity {
+		// The slab was full so it is not in the partial list
+		cls.pushSlab(s)
+	}
+
+	*(*unsafe.Pointer)(unsafe.Pointer(hdr)) = s.freeList
+	s.freeList = unsafe.Pointer(hdr)
+	s.used -= 1
+
+	if s.used > 0 {
+		cls.mtx.Unlock()
+		return
+	}
+
+	// The slab is empty, keep it if the class has no other empty slab. Else, hand the chunk to the shared pool so
+	// any size class can reuse it
+	cls.unlinkSlab(s)
+	if cls.empty == nil {
+		cls.empty = s
+		cls.mtx.Unlock()
+		return
+	}
+	cls.mtx.Unlock()
+
+	a.releaseChunk(s)
+}
+
+// Release gives the empty slabs cached by the size classes and the pooled chunks back to the backing allocator.
+// Slabs holding blocks still in use are kept, so it can be called at any time to trim the allocator. Once every
+// block is freed, nothing is left in the backing allocator after a Release.
+func (a *SlabAllocator) Release() {
+	for idx := range a.classes {
+		cls := &a.classes[idx]
+
+		cls.mtx.Lock()
+		s := cls.empty
+		cls.empty = nil
+		cls.mtx.Unlock()
+
+		if s != nil {
+			a.backing.Free(unsafe.Pointer(s))
+		}
+	}
+
+	a.chunksMtx.Lock()
+	s := a.freeChunks
+	a.freeChunks = nil
+	a.freeChunksCount = 0
+	a.chunksMtx.Unlock()
+
+	for s != nil {
+		next := s.next
+		a.backing.Free(unsafe.Pointer(s))
+		s = next
+	}
+}
+
+// Stats returns the usage statistics. Sizes account for whole blocks, including headers and padding.
+func (a *SlabAllocator) Stats() allocator.Stats {
+	return a.stats.Snapshot()
+}
+
+func (a *SlabAllocator) allocSmall(classIdx int) unsafe.Pointer {
+	var block unsafe.Pointer
+
+	cls := &a.classes[classIdx]
+
+	cls.mtx.Lock()
+	defer cls.mtx.Unlock()
+
+	s := cls.partial
+	if s == nil {
+		if cls.empty != nil {
+			s = cls.empty
+			cls.empty = nil
+		} else {
+			s = a.acquireChunk()
+			if s == nil {
+				return nil
+			}
+			s.class = classIdx
+			s.capacity = int((chunkSize - slabHeaderSize) / cls.blockSize)
+		}
+		cls.pushSlab(s)
+	}
+
+	if s.freeList != nil {
+		block = s.freeList
+		s.freeList = *(*unsafe.Pointer)(block)
+	} else {
+		block = unsafe.Add(unsafe.Pointer(s), s.bump)
+		s.bump += cls.blockSize
+	}
+	s.used += 1
+
+	if s.used == s.capacity {
+		cls.unlinkSlab(s)
+	}
+
+	hdr := (*blockHeader)(block)
+	hdr.slab = s
+	hdr.size = cls.blockSize
+	a.stats.RecordAlloc(cls.blockSize)
+	return unsafe.Add(block, blockHeaderSize)
+}
+
+func (a *SlabAllocator) acquireChunk() *slab {
+	var s *slab
+
+	a.chunksMtx.Lock()
+	if a.freeChunks != nil {
+		s = a.freeChunks
+		a.freeChunks = s.next
+		a.freeChunksCount -= 1
+	}
+	a.chunksMtx.Unlock()
+
+	if s == nil {
+		ptr := a.backing.Alloc(chunkSize)
+		if ptr == nil {
+			return nil
+		}
+		s = (*slab)(ptr)
+	}
+
+	*s = slab{
+		bump: slabHeaderSize,
+	}
+	return s
+}
+
+func (a *SlabAllocator) releaseChunk(s *slab) {
+	a.chunksMtx.Lock()
+	if a.freeChunksCount < maxFreeChunks {
+		s.next = a.freeChunks
+		a.freeChunks = s
+		a.freeChunksCount += 1
+		s = nil
+	}
+	a.chunksMtx.Unlock()
+
+	if s != nil {
+		a.backing.Free(unsafe.Pointer(s))
+	}
+}
+
+func (cls *sizeClass) pushSlab(s *slab) {
+	s.prev = nil
+	s.next = cls.partial
+	if cls.partial != nil {
+		cls.partial.prev = s
+	}
+	cls.partial = s
+}
+
+func (cls *sizeClass) unlinkSlab(s *slab) {
+	if s.prev != nil {
+		s.prev.next = s.next
+	} else {
+		cls.partial = s.next
+	}
+	if s.next != nil {
+		s.next.prev = s.prev
+	}
+	s.next = nil
+	s.prev = nil
+}
@@ -0,0 +1,85 @@
+package slab_test
+
+import (
+	"math/rand"
+	"sync"
+	"testing"
+
+	"github.com/mxmauro/unmanagedgen/allocator/internal/testalloc"
+	"github.com/mxmauro/unmanagedgen/allocator/slab"
+)
+
+// -----------------------------------------------------------------------------
+
+func TestSlabAllocator(t *testing.T) {
+	backing := testalloc.NewHeap()
+	alloc := slab.New(backing)
+
+	wg := sync.WaitGroup{}
+	for worker := 0; worker < 4; worker++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+
+			r := rand.New(rand.NewSource(seed))
+			live := make([]testalloc.Block, 0)
+			for idx := 0; idx < 20000; idx++ {
+				if len(live) > 0 && r.Intn(3) == 0 {
+					pos := r.Intn(len(live))
+					if !testalloc.CheckBlock(live[pos]) {
+						t.Errorf("block contents were modified")
+						return
+					}
+					alloc.Free(live[pos].Ptr)
+					live[pos] = live[len(live)-1]
+					live = live[:len(live)-1]
+					continue
+				}
+
+				b := testalloc.Block{
+					Size: uintptr(r.Intn(10000)),
+					Fill: byte(r.Intn(256)),
+				}
+				b.Ptr = alloc.Alloc(b.Size)
+				if b.Ptr == nil || uintptr(b.Ptr)&15 != 0 {
+					t.Errorf("invalid block returned")
+					return
+				}
+				testalloc.FillBlock(b)
+				live = append(live, b)
+			}
+
+			for _, b := range live {
+				if !testalloc.CheckBlock(b) {
+					t.Errorf("block contents were modified")
+					return
+				}
+				alloc.Free(b.Ptr)
+			}
+		}(int64(worker))
+	}
+	wg.Wait()
+
+	// Only the 16 pooled chunks and the empty slab kept by each of the 31 size classes can remain
+	if backing.Live() > 16+31 {
+		t.Fatalf("%v chunks were not given back to the backing allocator", backing.Live())
+	}
+}
+
+func TestSlabAllocatorKeepsEmptySlab(t *testing.T) {
+	backing := testalloc.NewHeap()
+	alloc := slab.New(backing)
+
+	// The emptied slab stays with its size class instead of going to the shared pool
+	alloc.Free(alloc.Alloc(64))
+	ptr := alloc.Alloc(1024)
+	if backing.Live() != 2 {
+		t.Fatalf("empty slab was not kept by its size class [chunks=%v]", backing.Live())
+	}
+	ptr2 := alloc.Alloc(64)
+	if backing.Live() != 2 {
+		t.Fatalf("cached slab was not reused [chunks=%v]", backing.Live())
+	}
+	alloc.Free(ptr)
+	alloc.Free(ptr2)
+}
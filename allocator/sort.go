@@ -0,0 +1,45 @@
+package allocator
+
+import (
+	"sort"
+)
+
+// -----------------------------------------------------------------------------
+
+type ptrSorter[E any] struct {
+	s   []E
+	cmp func(a, b *E) int
+}
+
+// -----------------------------------------------------------------------------
+
+// SortPtrFunc sorts s in ascending order as determined by cmp, which receives pointers to the elements so they are
+// never copied out of the slice. Elements are only swapped, so each one keeps the ownership of the memory it points
+// to. It is used by the generated Sort<Field>Func methods.
+func SortPtrFunc[E any](s []E, cmp func(a, b *E) int) {
+	sort.Sort(&ptrSorter[E]{
+		s:   s,
+		cmp: cmp,
+	})
+}
+
+// BinarySearchPtrFunc searches for target in s, which must be sorted in ascending order as determined by cmp, and
+// returns the position where it is found, or where it would be inserted, and whether it was found.
+func BinarySearchPtrFunc[E any](s []E, target *E, cmp func(a, b *E) int) (int, bool) {
+	idx := sort.Search(len(s), func(i int) bool {
+		return cmp(&s[i], target) >= 0
+	})
+	return idx, idx < len(s) && cmp(&s[idx], target) == 0
+}
+
+func (ps *ptrSorter[E]) Len() int {
+	return len(ps.s)
+}
+
+func (ps *ptrSorter[E]) Less(i, j int) bool {
+	return ps.cmp(&ps.s[i], &ps.s[j]) < 0
+}
+
+func (ps *ptrSorter[E]) Swap(i, j int) {
+	ps.s[i], ps.s[j] = ps.s[j], ps.s[i]
+}
@@ -0,0 +1,47 @@
+package allocator_test
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/mxmauro/unmanagedgen/allocator"
+)
+
+// -----------------------------------------------------------------------------
+
+func TestSortPtrFunc(t *testing.T) {
+	s := make([]int, 1000)
+	for idx := range s {
+		s[idx] = rand.Intn(500)
+	}
+	cmp := func(a, b *int) int {
+		return *a - *b
+	}
+
+	allocator.SortPtrFunc(s, cmp)
+	for idx := 1; idx < len(s); idx++ {
+		if s[idx-1] > s[idx] {
+			t.Fatalf("slice not sorted at position %v", idx)
+		}
+	}
+
+	target := s[123]
+	idx, found := allocator.BinarySearchPtrFunc(s, &target, cmp)
+	if !found || s[idx] != target {
+		t.Fatalf("element not found")
+	}
+	target = 1000
+	idx, found = allocator.BinarySearchPtrFunc(s, &target, cmp)
+	if found || idx != len(s) {
+		t.Fatalf("unexpected result for a missing element [idx=%v]", idx)
+	}
+
+	strs := []string{"b", "c", "a"}
+	allocator.SortPtrFunc(strs, func(a, b *string) int {
+		return strings.Compare(*a, *b)
+	})
+	if strings.Join(strs, "") != "abc" {
+		t.Fatalf("strings not sorted")
+	}
+}
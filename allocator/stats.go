@@ -0,0 +1,133 @@
+package allocator
+
+import (
+	"math"
+	"math/bits"
+	"runtime/metrics"
+	"strconv"
+	"sync/atomic"
+)
+
+// -----------------------------------------------------------------------------
+
+// Metric names used by Stats.Metrics. They follow the runtime/metrics naming scheme so unmanaged memory can be
+// graphed next to the Go heap statistics.
+const (
+	MetricBytesInUse    = "/unmanaged/heap/objects:bytes"
+	MetricHighWaterMark = "/unmanaged/heap/peak:bytes"
+	MetricAllocs        = "/unmanaged/heap/allocs:objects"
+	MetricFrees         = "/unmanaged/heap/frees:objects"
+	MetricAllocsBySize  = "/unmanaged/heap/allocs-by-size:bytes"
+)
+
+const histogramBuckets = 65
+
+// -----------------------------------------------------------------------------
+
+// StatsProvider is an optional interface implemented by allocators that keep usage statistics.
+type StatsProvider interface {
+	Stats() Stats
+}
+
+// Stats is a snapshot of the activity of an allocator.
+type Stats struct {
+	BytesInUse    uint64
+	HighWaterMark uint64
+	Allocs        uint64
+	Frees         uint64
+
+	// SizeHistogram counts allocations by size using power-of-two buckets. Bucket 0 holds zero-sized allocations.
+	SizeHistogram *metrics.Float64Histogram
+}
+
+// StatsCounter is a helper that allocator implementations can embed to keep statistics. It is safe for concurrent
+// use.
+type StatsCounter struct {
+	inUse     atomic.Int64
+	peak      atomic.Uint64
+	allocs    atomic.Uint64
+	frees     atomic.Uint64
+	histogram [histogramBuckets]atomic.Uint64
+}
+
+// -----------------------------------------------------------------------------
+
+// RecordAlloc accounts for a new block of the given size.
+func (sc *StatsCounter) RecordAlloc(size uintptr) {
+	sc.allocs.Add(1)
+	sc.histogram[bits.Len64(uint64(size))].Add(1)
+
+	inUse := uint64(sc.inUse.Add(int64(size)))
+	for {
+		peak := sc.peak.Load()
+		if inUse <= peak || sc.peak.CompareAndSwap(peak, inUse) {
+			break
+		}
+	}
+}
+
+// RecordFree accounts for a released block of the given size and returns the bytes still in use.
+func (sc *StatsCounter) RecordFree(size uintptr) int64 {
+	sc.frees.Add(1)
+	return sc.inUse.Add(-int64(size))
+}
+
+// RecordBulkFree accounts for several blocks released at once.
+func (sc *StatsCounter) RecordBulkFree(count uint64, size uint64) {
+	sc.frees.Add(count)
+	sc.inUse.Add(-int64(size))
+}
+
+// InUse returns the bytes currently in use.
+func (sc *StatsCounter) InUse() int64 {
+	return sc.inUse.Load()
+}
+
+// Snapshot returns the current statistics.
+func (sc *StatsCounter) Snapshot() Stats {
+	h := metrics.Float64Histogram{
+		Counts:  make([]uint64, histogramBuckets),
+		Buckets: make([]float64, histogramBuckets+1),
+	}
+	for idx := range sc.histogram {
+		h.Counts[idx] = sc.histogram[idx].Load()
+		if idx > 0 {
+			h.Buckets[idx] = float64(uint64(1) << (idx - 1))
+		}
+	}
+	h.Buckets[histogramBuckets] = math.Inf(1)
+
+	inUse := sc.inUse.Load()
+	if inUse < 0 {
+		inUse = 0
+	}
+	return Stats{
+		BytesInUse:    uint64(inUse),
+		HighWaterMark: sc.peak.Load(),
+		Allocs:        sc.allocs.Load(),
+		Frees:         sc.frees.Load(),
+		SizeHistogram: &h,
+	}
+}
+
+// Metrics returns the statistics keyed by their metric names. The histogram is returned as a map with the lower
+// bound of each non-empty bucket and its count.
+func (s Stats) Metrics() map[string]any {
+	m := map[string]any{
+		MetricBytesInUse:    s.BytesInUse,
+		MetricHighWaterMark: s.HighWaterMark,
+		MetricAllocs:        s.Allocs,
+		MetricFrees:         s.Frees,
+	}
+
+	buckets := make(map[string]uint64)
+	if s.SizeHistogram != nil {
+		for idx, count := range s.SizeHistogram.Counts {
+			if count > 0 {
+				buckets[strconv.FormatUint(uint64(s.SizeHistogram.Buckets[idx]), 10)] = count
+			}
+		}
+	}
+	m[MetricAllocsBySize] = buckets
+	return m
+}
@@ -0,0 +1,47 @@
+package allocator_test
+
+import (
+	"testing"
+
+	"github.com/mxmauro/unmanagedgen/allocator"
+)
+
+// -----------------------------------------------------------------------------
+
+func TestStatsCounter(t *testing.T) {
+	sc := allocator.StatsCounter{}
+
+	sc.RecordAlloc(0)
+	sc.RecordAlloc(100)
+	sc.RecordAlloc(4096)
+	sc.RecordFree(4096)
+	sc.RecordAlloc(10)
+
+	s := sc.Snapshot()
+	if s.BytesInUse != 110 || s.HighWaterMark != 4196 || s.Allocs != 4 || s.Frees != 1 {
+		t.Fatalf("unexpected stats: %+v", s)
+	}
+
+	h := s.SizeHistogram
+	if len(h.Buckets) != len(h.Counts)+1 {
+		t.Fatalf("invalid histogram layout")
+	}
+	for idx, count := range h.Counts {
+		expected := uint64(0)
+		switch h.Buckets[idx] {
+		case 0, 64, 4096, 8:
+			expected = 1
+		}
+		if count != expected {
+			t.Fatalf("unexpected count %v in bucket starting at %v", count, h.Buckets[idx])
+		}
+	}
+
+	m := s.Metrics()
+	if m[allocator.MetricBytesInUse] != uint64(110) {
+		t.Fatalf("unexpected metrics: %v", m)
+	}
+	if bySize := m[allocator.MetricAllocsBySize].(map[string]uint64); bySize["4096"] != 1 || len(bySize) != 4 {
+		t.Fatalf("unexpected size histogram: %v", bySize)
+	}
+}
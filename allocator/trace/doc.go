@@ -0,0 +1,3 @@
+// Package trace records every Alloc and Free made through an allocator into a compact binary trace, and replays
+// traces against other allocators to compare their throughput, peak usage and fragmentation.
+package trace
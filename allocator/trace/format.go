@@ -0,0 +1,173 @@
+package trace
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"time"
+)
+
+// -----------------------------------------------------------------------------
+
+// A trace starts with the magic string and the format version. Then records follow, each one an operation byte and
+// its uvarint-encoded fields:
+//
+//	opAlloc: block id, size, nanoseconds since the previous record, label id (0 = none)
+//	opFree:  block id, nanoseconds since the previous record
+//	opLabel: label id, length, label bytes (defines a label before its first use)
+const (
+	magic   = "UMGTRACE"
+	version = 1
+
+	opAlloc = 1
+	opFree  = 2
+	opLabel = 3
+
+	maxLabelLen = 1024
+)
+
+// ErrInvalidTrace is returned when the data is not a trace or is corrupted.
+var ErrInvalidTrace = errors.New("invalid allocation trace")
+
+// -----------------------------------------------------------------------------
+
+// Op is the kind of operation of an Event.
+type Op int
+
+const (
+	OpAlloc Op = iota
+	OpFree
+)
+
+// Event is an operation read from a trace.
+type Event struct {
+	Op Op
+
+	// ID identifies the block. It is unique within a trace.
+	ID uint64
+
+	// Size is the requested size. Only set for allocations.
+	Size uintptr
+
+	// Time is the moment of the operation, relative to the start of the recording.
+	Time time.Duration
+
+	// Label is the type label given to the allocation, if any.
+	Label string
+}
+
+// Reader decodes a trace.
+type Reader struct {
+	r      *bufio.Reader
+	now    time.Duration
+	labels map[uint64]string
+}
+
+// -----------------------------------------------------------------------------
+
+// NewReader creates a new trace reader. It returns ErrInvalidTrace if the data does not start with a trace header.
+func NewReader(r io.Reader) (*Reader, error) {
+	br := bufio.NewReader(r)
+
+	hdr := make([]byte, len(magic)+1)
+	if _, err := io.ReadFull(br, hdr); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, ErrInvalidTrace
+		}
+		return nil, err
+	}
+	if string(hdr[:len(magic)]) != magic || hdr[len(magic)] != version {
+		return nil, ErrInvalidTrace
+	}
+
+	return &Reader{
+		r:      br,
+		labels: make(map[uint64]string),
+	}, nil
+}
+
+// Next returns the next event. It returns io.EOF at the end of the trace.
+func (tr *Reader) Next() (Event, error) {
+	for {
+		op, err := tr.r.ReadByte()
+		if err != nil {
+			return Event{}, err
+		}
+
+		switch op {
+		case opAlloc:
+			var fields [4]uint64
+
+			if err = tr.readUvarints(fields[:]); err != nil {
+				return Event{}, err
+			}
+			tr.now += time.Duration(fields[2])
+			return Event{
+				Op:    OpAlloc,
+				ID:    fields[0],
+				Size:  uintptr(fields[1]),
+				Time:  tr.now,
+				Label: tr.labels[fields[3]],
+			}, nil
+
+		case opFree:
+			var fields [2]uint64
+
+			if err = tr.readUvarints(fields[:]); err != nil {
+				return Event{}, err
+			}
+			tr.now += time.Duration(fields[1])
+			return Event{
+				Op:   OpFree,
+				ID:   fields[0],
+				Time: tr.now,
+			}, nil
+
+		case opLabel:
+			var fields [2]uint64
+
+			if err = tr.readUvarints(fields[:]); err != nil {
+				return Event{}, err
+			}
+			if fields[1] > maxLabelLen {
+				return Event{}, ErrInvalidTrace
+			}
+			label := make([]byte, fields[1])
+			if _, err = io.ReadFull(tr.r, label); err != nil {
+				return Event{}, ErrInvalidTrace
+			}
+			tr.labels[fields[0]] = string(label)
+
+		default:
+			return Event{}, ErrInvalidTrace
+		}
+	}
+}
+
+// ReadAll reads all the remaining events.
+func (tr *Reader) ReadAll() ([]Event, error) {
+	events := make([]Event, 0)
+	for {
+		ev, err := tr.Next()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return events, nil
+			}
+			return nil, err
+		}
+		events = append(events, ev)
+	}
+}
+
+func (tr *Reader) readUvarints(fields []uint64) error {
+	for idx := range fields {
+		v, err := binary.ReadUvarint(tr.r)
+		if err != nil {
+			// A record cut in half
+			return ErrInvalidTrace
+		}
+		fields[idx] = v
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package trace
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"sync"
+	"time"
+	"unsafe"
+
+	"github.com/mxmauro/unmanagedgen/allocator"
+)
+
+// -----------------------------------------------------------------------------
+
+// Recorder is an allocator.Middleware that writes every allocation and release made through the chain to a trace.
+// A reallocation is recorded as the allocation of the new block followed by the release of the old one. Alignments
+// are not recorded.
+//
+// The trace is buffered, call Flush once done. Writing stops at the first error, which is reported by Err and Flush.
+type Recorder struct {
+	base allocator.Allocator
+
+	mtx     sync.Mutex
+	w       *bufio.Writer
+	err     error
+	last    time.Time
+	nextID  uint64
+	blocks  map[unsafe.Pointer]uint64
+	labels  map[string]uint64
+	scratch [1 + 4*binary.MaxVarintLen64]byte
+}
+
+// labeledRecorder records allocations with a type label.
+type labeledRecorder struct {
+	rec   *Recorder
+	label string
+}
+
+// -----------------------------------------------------------------------------
+
+// New creates a new recording allocator on top of the given one. It returns the allocator, which exposes the same
+// optional interfaces as allocator.Chain so the recorded workload behaves as it does without it, and the recorder.
+// The trace header is written right away.
+func New(base allocator.Allocator, w io.Writer) (allocator.Allocator, *Recorder) {
+	rec := &Recorder{
+		base:   base,
+		w:      bufio.NewWriter(w),
+		blocks: make(map[unsafe.Pointer]uint64),
+		labels: make(map[string]uint64),
+	}
+	rec.last = time.Now()
+
+	_, rec.err = rec.w.WriteString(magic)
+	if rec.err == nil {
+		rec.err = rec.w.WriteByte(version)
+	}
+	return allocator.Chain(base, rec), rec
+}
+
+func (rec *Recorder) Alloc(size uintptr, next allocator.AllocFunc) unsafe.Pointer {
+	return rec.alloc(size, "", next)
+}
+
+func (rec *Recorder) Free(ptr unsafe.Pointer, next allocator.FreeFunc) {
+	if ptr == nil {
+		next(ptr)
+		return
+	}
+
+	rec.mtx.Lock()
+	id, found := rec.blocks[ptr]
+	delete(rec.blocks, ptr)
+	// Record before releasing the block so the id cannot be reused by a concurrent allocation in the trace order
+	if found {
+		rec.writeRecord(opFree, id, rec.elapsed())
+	}
+	rec.mtx.Unlock()
+
+	next(ptr)
+}
+
+// WithLabel returns an allocator on top of the same base that records its allocations with the given type label, for
+// example, the name of the struct being allocated. Frees can be done through any of them.
+func (rec *Recorder) WithLabel(label string) allocator.Allocator {
+	if len(label) > maxLabelLen {
+		label = label[:maxLabelLen]
+	}
+	return allocator.Chain(rec.base, &labeledRecorder{
+		rec:   rec,
+		label: label,
+	})
+}
+
+// Flush writes any buffered data to the underlying writer.
+func (rec *Recorder) Flush() error {
+	rec.mtx.Lock()
+	defer rec.mtx.Unlock()
+
+	if rec.err == nil {
+		rec.err = rec.w.Flush()
+	}
+	return rec.err
+}
+
+// Err returns the first error found while writing the trace.
+func (rec *Recorder) Err() error {
+	rec.mtx.Lock()
+	defer rec.mtx.Unlock()
+
+	return rec.err
+}
+
+func (rec *Recorder) alloc(size uintptr, label string, next allocator.AllocFunc) unsafe.Pointer {
+	ptr := next(size)
+	if ptr == nil {
+		return nil
+	}
+
+	rec.mtx.Lock()
+	labelID := uint64(0)
+	if len(label) > 0 {
+		labelID = rec.labelID(label)
+	}
+	if id, found := rec.blocks[ptr]; found {
+		// A live address handed out again means the block was resized in place, or released in bulk, without a Free
+		rec.writeRecord(opFree, id, rec.elapsed())
+	}
+	rec.nextID += 1
+	rec.blocks[ptr] = rec.nextID
+	rec.writeRecord(opAlloc, rec.nextID, uint64(size), rec.elapsed(), labelID)
+	rec.mtx.Unlock()
+
+	return ptr
+}
+
+// labelID returns the id of the label, writing its definition the first time. Must be called with the mutex held.
+func (rec *Recorder) labelID(label string) uint64 {
+	id, found := rec.labels[label]
+	if !found {
+		id = uint64(len(rec.labels) + 1)
+		rec.labels[label] = id
+		rec.writeRecord(opLabel, id, uint64(len(label)))
+		if rec.err == nil {
+			_, rec.err = rec.w.WriteString(label)
+		}
+	}
+	return id
+}
+
+// elapsed returns the nanoseconds since the previous record. Must be called with the mutex held.
+func (rec *Recorder) elapsed() uint64 {
+	now := time.Now()
+	d := now.Sub(rec.last)
+	rec.last = now
+	if d < 0 {
+		return 0
+	}
+	return uint64(d)
+}
+
+// writeRecord must be called with the mutex held.
+func (rec *Recorder) writeRecord(op byte, fields ...uint64) {
+	if rec.err != nil {
+		return
+	}
+	buf := rec.scratch[:0]
+	buf = append(buf, op)
+	for _, v := range fields {
+		buf = binary.AppendUvarint(buf, v)
+	}
+	_, rec.err = rec.w.Write(buf)
+}
+
+func (lr *labeledRecorder) Alloc(size uintptr, next allocator.AllocFunc) unsafe.Pointer {
+	return lr.rec.alloc(size, lr.label, next)
+}
+
+func (lr *labeledRecorder) Free(ptr unsafe.Pointer, next allocator.FreeFunc) {
+	lr.rec.Free(ptr, next)
+}
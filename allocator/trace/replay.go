@@ -0,0 +1,113 @@
+package trace
+
+import (
+	"time"
+	"unsafe"
+
+	"github.com/mxmauro/unmanagedgen/allocator"
+)
+
+// -----------------------------------------------------------------------------
+
+// ReplayResult summarizes a replay.
+type ReplayResult struct {
+	Allocs       uint64
+	Frees        uint64
+	FailedAllocs uint64
+
+	// Duration is the time spent replaying the events, without the time spent in the footprint function.
+	Duration time.Duration
+
+	// PeakInUse is the maximum number of requested bytes alive at once.
+	PeakInUse uint64
+
+	// PeakFootprint is the maximum number of bytes held by the allocator at once, as reported by the footprint
+	// function given to Replay. Zero if it is unknown.
+	PeakFootprint uint64
+}
+
+// -----------------------------------------------------------------------------
+
+// Replay runs the events against the allocator, as fast as possible. Frees of blocks that were not allocated in the
+// trace are ignored and blocks still alive at the end are released.
+//
+// The whole loop is timed, because timing each call would add more overhead than the calls themselves, so Duration
+// also includes the bookkeeping of the replay. If footprint is not nil, it is called after every operation to track
+// the memory held by the allocator, including its overhead and free lists. Its time is excluded.
+func Replay(events []Event, alloc allocator.Allocator, footprint func() uint64) ReplayResult {
+	var res ReplayResult
+	var inUse uint64
+
+	type liveBlock struct {
+		ptr  unsafe.Pointer
+		size uintptr
+	}
+	live := make(map[uint64]liveBlock, 1024)
+
+	var footprintTime time.Duration
+	start := time.Now()
+	for _, ev := range events {
+		switch ev.Op {
+		case OpAlloc:
+			ptr := alloc.Alloc(ev.Size)
+			if ptr == nil {
+				res.FailedAllocs += 1
+				continue
+			}
+			res.Allocs += 1
+			live[ev.ID] = liveBlock{
+				ptr:  ptr,
+				size: ev.Size,
+			}
+			inUse += uint64(ev.Size)
+			if inUse > res.PeakInUse {
+				res.PeakInUse = inUse
+			}
+
+		case OpFree:
+			blk, found := live[ev.ID]
+			if !found {
+				continue
+			}
+			delete(live, ev.ID)
+			alloc.Free(blk.ptr)
+			res.Frees += 1
+			inUse -= uint64(blk.size)
+		}
+
+		if footprint != nil {
+			fpStart := time.Now()
+			if fp := footprint(); fp > res.PeakFootprint {
+				res.PeakFootprint = fp
+			}
+			footprintTime += time.Since(fpStart)
+		}
+	}
+	res.Duration = time.Since(start) - footprintTime
+
+	for _, blk := range live {
+		alloc.Free(blk.ptr)
+	}
+
+	return res
+}
+
+// Throughput returns the number of operations per second.
+func (r ReplayResult) Throughput() float64 {
+	if r.Duration <= 0 {
+		return 0
+	}
+	return float64(r.Allocs+r.Frees) / r.Duration.Seconds()
+}
+
+// Fragmentation returns the fraction of the peak footprint that was not holding live data, from 0 to 1. It returns
+// a negative value if the footprint is unknown.
+func (r ReplayResult) Fragmentation() float64 {
+	if r.PeakFootprint == 0 {
+		return -1
+	}
+	if r.PeakInUse >= r.PeakFootprint {
+		return 0
+	}
+	return 1 - float64(r.PeakInUse)/float64(r.PeakFootprint)
+}
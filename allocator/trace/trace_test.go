@@ -0,0 +1,152 @@
+package trace_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"unsafe"
+
+	"github.com/mxmauro/unmanagedgen/allocator"
+	"github.com/mxmauro/unmanagedgen/allocator/internal/testalloc"
+	"github.com/mxmauro/unmanagedgen/allocator/trace"
+)
+
+// -----------------------------------------------------------------------------
+
+// reallocHeapAllocator moves blocks when they grow and resizes them in place when they shrink. It also claims that
+// Free does nothing, only to check that the recorder forwards BulkReleaser.
+type reallocHeapAllocator struct {
+	*testalloc.HeapAllocator
+}
+
+// -----------------------------------------------------------------------------
+
+func TestRecordAndReplay(t *testing.T) {
+	var buf bytes.Buffer
+
+	alloc, rec := trace.New(testalloc.NewHeap(), &buf)
+	labeled := rec.WithLabel("Sample")
+
+	ptr1 := alloc.Alloc(100)
+	ptr2 := labeled.Alloc(200)
+	ptr3 := labeled.Alloc(300)
+	alloc.Free(ptr1)
+	labeled.Free(ptr3)
+	alloc.Free(ptr2)
+	if err := rec.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	tr, err := trace.NewReader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	events, err := tr.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 6 {
+		t.Fatalf("unexpected number of events [%v]", len(events))
+	}
+	if events[0].Op != trace.OpAlloc || events[0].Size != 100 || events[0].Label != "" ||
+		events[2].Op != trace.OpAlloc || events[2].Size != 300 || events[2].Label != "Sample" ||
+		events[4].Op != trace.OpFree || events[4].ID != events[2].ID {
+		t.Fatalf("unexpected events [%+v]", events)
+	}
+	for idx := 1; idx < len(events); idx++ {
+		if events[idx].Time < events[idx-1].Time {
+			t.Fatalf("timestamps are not monotonic")
+		}
+	}
+
+	target := testalloc.NewHeap()
+	res := trace.Replay(events, target, func() uint64 {
+		return target.InUse()
+	})
+	if res.Allocs != 3 || res.Frees != 3 || res.PeakInUse != 600 || res.PeakFootprint != 600 ||
+		res.Fragmentation() != 0 || target.Live() != 0 {
+		t.Fatalf("unexpected replay result [%+v]", res)
+	}
+}
+
+func TestRecordOptionalInterfaces(t *testing.T) {
+	var buf bytes.Buffer
+
+	base := &reallocHeapAllocator{
+		HeapAllocator: testalloc.NewHeap(),
+	}
+	alloc, rec := trace.New(base, &buf)
+	ra, ok := alloc.(allocator.Reallocator)
+	if !ok {
+		t.Fatalf("recorder does not implement Reallocator")
+	}
+	if !allocator.FreeIsNoop(alloc) {
+		t.Fatalf("FreeIsNoop not forwarded")
+	}
+
+	ptr := allocator.AllocZeroed(alloc, 16)
+	ptr = ra.Realloc(ptr, 64)
+	// Resizing in place hands out the same address again
+	ptr = ra.Realloc(ptr, 32)
+	alloc.Free(ptr)
+	if err := rec.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	tr, err := trace.NewReader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	events, err := tr.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 6 || events[1].Op != trace.OpAlloc || events[1].Size != 64 ||
+		events[2].Op != trace.OpFree || events[2].ID != events[0].ID ||
+		events[3].Op != trace.OpFree || events[3].ID != events[1].ID ||
+		events[4].Op != trace.OpAlloc || events[4].Size != 32 ||
+		events[5].Op != trace.OpFree || events[5].ID != events[4].ID {
+		t.Fatalf("unexpected events [%+v]", events)
+	}
+
+	target := testalloc.NewHeap()
+	res := trace.Replay(events, target, nil)
+	if res.Allocs != 3 || res.Frees != 3 || target.Live() != 0 || base.Live() != 0 {
+		t.Fatalf("unexpected replay result [%+v]", res)
+	}
+}
+
+func TestInvalidTrace(t *testing.T) {
+	_, err := trace.NewReader(bytes.NewReader([]byte("not a trace")))
+	if !errors.Is(err, trace.ErrInvalidTrace) {
+		t.Fatalf("unexpected error [err=%v]", err)
+	}
+
+	var buf bytes.Buffer
+	alloc, rec := trace.New(testalloc.NewHeap(), &buf)
+	alloc.Free(alloc.Alloc(1000))
+	_ = rec.Flush()
+
+	// Cut the last record
+	tr, err := trace.NewReader(bytes.NewReader(buf.Bytes()[:buf.Len()-1]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = tr.ReadAll(); !errors.Is(err, trace.ErrInvalidTrace) {
+		t.Fatalf("unexpected error [err=%v]", err)
+	}
+}
+
+func (a *reallocHeapAllocator) Realloc(ptr unsafe.Pointer, size uintptr) unsafe.Pointer {
+	if size <= uintptr(len(a.Block(ptr))) {
+		return ptr
+	}
+	newPtr := a.Alloc(size)
+	copy(a.Block(newPtr), a.Block(ptr))
+	a.Free(ptr)
+	return newPtr
+}
+
+func (a *reallocHeapAllocator) FreeIsNoop() bool {
+	return true
+}
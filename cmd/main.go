@@ -35,6 +35,8 @@ func main() {
 	rootCmd.Flags().StringVarP(&settings.FileMask, "file", "F", "", "File specification containing definitions to process")
 	_ = rootCmd.MarkFlagRequired("file")
 
+	rootCmd.AddCommand(newReplayCommand())
+
 	if err := rootCmd.Execute(); err != nil {
 		os.Exit(1)
 	}
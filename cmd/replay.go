@@ -0,0 +1,188 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"unsafe"
+
+	"github.com/mxmauro/unmanagedgen/allocator"
+	"github.com/mxmauro/unmanagedgen/allocator/arena"
+	"github.com/mxmauro/unmanagedgen/allocator/slab"
+	"github.com/mxmauro/unmanagedgen/allocator/trace"
+	"github.com/spf13/cobra"
+)
+
+// -----------------------------------------------------------------------------
+
+// allocatorFactory creates a fresh allocator for a replay. The returned function, if not nil, reports the bytes the
+// allocator currently holds.
+type allocatorFactory func() (allocator.Allocator, func() uint64)
+
+// meteredAllocator tracks the bytes handed out by a backing allocator, so the footprint of allocators built on top
+// of it can be measured.
+type meteredAllocator struct {
+	base allocator.Allocator
+
+	mtx       sync.Mutex
+	blocks    map[unsafe.Pointer]uintptr
+	footprint uint64
+}
+
+// -----------------------------------------------------------------------------
+
+var replaySettings struct {
+	TraceFile  string
+	Allocators []string
+}
+
+var allocatorRegistry = make(map[string]allocatorFactory)
+
+// -----------------------------------------------------------------------------
+
+func registerAllocator(name string, factory allocatorFactory) {
+	allocatorRegistry[name] = factory
+}
+
+// registerBackedAllocators registers the allocators that are built on top of another one. It is called by the
+// build-specific file that provides the backing allocator.
+func registerBackedAllocators(backing func() allocator.Allocator) {
+	registerAllocator("slab", func() (allocator.Allocator, func() uint64) {
+		m := newMeteredAllocator(backing())
+		return slab.New(m), m.Footprint
+	})
+	registerAllocator("arena", func() (allocator.Allocator, func() uint64) {
+		m := newMeteredAllocator(backing())
+		return arena.New(m, arena.DefaultChunkSize), m.Footprint
+	})
+}
+
+func registeredAllocators() []string {
+	names := make([]string, 0, len(allocatorRegistry))
+	for name := range allocatorRegistry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func newReplayCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "replay --trace file [--allocator name]...",
+		Short: "Replay an allocation trace against registered allocators and report throughput, peak usage and fragmentation",
+		Run: func(cmd *cobra.Command, args []string) {
+			exitCode := runReplay()
+			if exitCode != 0 {
+				os.Exit(exitCode)
+			}
+		},
+	}
+
+	cmd.Flags().StringVarP(&replaySettings.TraceFile, "trace", "T", "", "Trace file recorded with trace.Recorder")
+	_ = cmd.MarkFlagRequired("trace")
+	cmd.Flags().StringSliceVarP(&replaySettings.Allocators, "allocator", "A", nil,
+		"Allocators to replay the trace against (default all). Available: "+strings.Join(registeredAllocators(), ", "))
+
+	return cmd
+}
+
+func runReplay() int {
+	names := replaySettings.Allocators
+	if len(names) == 0 {
+		names = registeredAllocators()
+	}
+	for _, name := range names {
+		if _, ok := allocatorRegistry[name]; !ok {
+			fmt.Printf("Error: unknown allocator %v\n", name)
+			return 1
+		}
+	}
+
+	f, err := os.Open(replaySettings.TraceFile)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err.Error())
+		return 1
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	tr, err := trace.NewReader(f)
+	if err == nil {
+		var events []trace.Event
+
+		events, err = tr.ReadAll()
+		if err == nil {
+			for _, name := range names {
+				alloc, footprint := allocatorRegistry[name]()
+				res := trace.Replay(events, alloc, footprint)
+				releaseAllocator(alloc)
+				printReplayResult(name, res)
+			}
+		}
+	}
+	if err != nil {
+		fmt.Printf("Error: %v\n", err.Error())
+		return 1
+	}
+	return 0
+}
+
+func printReplayResult(name string, res trace.ReplayResult) {
+	fmt.Printf("Allocator: %v\n", name)
+	fmt.Printf("  Operations:     %v allocs, %v frees, %v failed\n", res.Allocs, res.Frees, res.FailedAllocs)
+	fmt.Printf("  Time:           %v\n", res.Duration)
+	fmt.Printf("  Throughput:     %.0f ops/s\n", res.Throughput())
+	fmt.Printf("  Peak in use:    %v bytes\n", res.PeakInUse)
+	if frag := res.Fragmentation(); frag >= 0 {
+		fmt.Printf("  Peak footprint: %v bytes\n", res.PeakFootprint)
+		fmt.Printf("  Fragmentation:  %.1f%%\n", frag*100)
+	} else {
+		// Only allocators built on top of a metered backing allocator can report it
+		fmt.Printf("  Peak footprint: unknown (not measurable for this allocator)\n")
+	}
+}
+
+// releaseAllocator gives the memory still held by the allocator back to its backing allocator, if it keeps any, so
+// it does not skew the replays that follow.
+func releaseAllocator(alloc allocator.Allocator) {
+	if r, ok := alloc.(interface{ Release() }); ok {
+		r.Release()
+	}
+}
+
+func newMeteredAllocator(base allocator.Allocator) *meteredAllocator {
+	return &meteredAllocator{
+		base:   base,
+		blocks: make(map[unsafe.Pointer]uintptr),
+	}
+}
+
+func (m *meteredAllocator) Alloc(size uintptr) unsafe.Pointer {
+	ptr := m.base.Alloc(size)
+	if ptr != nil {
+		m.mtx.Lock()
+		m.blocks[ptr] = size
+		m.footprint += uint64(size)
+		m.mtx.Unlock()
+	}
+	return ptr
+}
+
+func (m *meteredAllocator) Free(ptr unsafe.Pointer) {
+	m.mtx.Lock()
+	m.footprint -= uint64(m.blocks[ptr])
+	delete(m.blocks, ptr)
+	m.mtx.Unlock()
+
+	m.base.Free(ptr)
+}
+
+func (m *meteredAllocator) Footprint() uint64 {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	return m.footprint
+}
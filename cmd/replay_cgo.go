@@ -0,0 +1,21 @@
+//go:build cgo
+
+package main
+
+import (
+	"github.com/mxmauro/unmanagedgen/allocator"
+	"github.com/mxmauro/unmanagedgen/allocator/c"
+)
+
+// -----------------------------------------------------------------------------
+
+func init() {
+	registerAllocator("c", func() (allocator.Allocator, func() uint64) {
+		return c.New(), nil
+	})
+
+	// The C allocator has precedence as backing allocator
+	registerBackedAllocators(func() allocator.Allocator {
+		return c.New()
+	})
+}
@@ -0,0 +1,16 @@
+//go:build unix
+
+package main
+
+import (
+	"github.com/mxmauro/unmanagedgen/allocator"
+	"github.com/mxmauro/unmanagedgen/allocator/mmap"
+)
+
+// -----------------------------------------------------------------------------
+
+func init() {
+	registerAllocator("mmap", func() (allocator.Allocator, func() uint64) {
+		return mmap.New(), nil
+	})
+}
@@ -0,0 +1,17 @@
+//go:build unix && !cgo
+
+package main
+
+import (
+	"github.com/mxmauro/unmanagedgen/allocator"
+	"github.com/mxmauro/unmanagedgen/allocator/mmap"
+)
+
+// -----------------------------------------------------------------------------
+
+func init() {
+	// Without cgo, the mmap allocator backs slab and arena
+	registerBackedAllocators(func() allocator.Allocator {
+		return mmap.New()
+	})
+}
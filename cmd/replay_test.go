@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"unsafe"
+
+	"github.com/mxmauro/unmanagedgen/allocator/trace"
+)
+
+// -----------------------------------------------------------------------------
+
+type heapAllocator struct {
+	blocks map[unsafe.Pointer][]byte
+}
+
+// -----------------------------------------------------------------------------
+
+func TestMeteredAllocator(t *testing.T) {
+	m := newMeteredAllocator(newHeapAllocator())
+
+	ptr1 := m.Alloc(100)
+	ptr2 := m.Alloc(200)
+	if m.Footprint() != 300 {
+		t.Fatalf("unexpected footprint [%v]", m.Footprint())
+	}
+	m.Free(ptr1)
+	if m.Footprint() != 200 {
+		t.Fatalf("unexpected footprint [%v]", m.Footprint())
+	}
+	m.Free(ptr2)
+	if m.Footprint() != 0 || len(m.blocks) != 0 {
+		t.Fatalf("unexpected footprint [%v]", m.Footprint())
+	}
+}
+
+func TestRunReplay(t *testing.T) {
+	var buf bytes.Buffer
+
+	alloc, rec := trace.New(newHeapAllocator(), &buf)
+	ptrs := make([]unsafe.Pointer, 0)
+	for idx := 0; idx < 100; idx++ {
+		ptrs = append(ptrs, alloc.Alloc(uintptr(16+idx)))
+	}
+	for _, ptr := range ptrs {
+		alloc.Free(ptr)
+	}
+	if err := rec.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	traceFile := filepath.Join(t.TempDir(), "trace.bin")
+	if err := os.WriteFile(traceFile, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tr, err := trace.NewReader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	events, err := tr.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func(saved []string) {
+		replaySettings.TraceFile = ""
+		replaySettings.Allocators = saved
+	}(replaySettings.Allocators)
+
+	replaySettings.TraceFile = traceFile
+	replaySettings.Allocators = nil
+	if exitCode := runReplay(); exitCode != 0 {
+		t.Fatalf("replay against all allocators failed [exitCode=%v]", exitCode)
+	}
+
+	replaySettings.Allocators = []string{"unknown"}
+	if exitCode := runReplay(); exitCode == 0 {
+		t.Fatalf("unknown allocator accepted")
+	}
+
+	// slab and arena are only registered when the build has a backing allocator for them
+	for _, name := range []string{"slab", "arena"} {
+		if factory, ok := allocatorRegistry[name]; ok {
+			alloc, footprint := factory()
+			trace.Replay(events, alloc, footprint)
+			releaseAllocator(alloc)
+			if footprint() != 0 {
+				t.Fatalf("%v still holds %v bytes after being released", name, footprint())
+			}
+		}
+
+		replaySettings.Allocators = []string{name}
+		exitCode := runReplay()
+		if _, ok := allocatorRegistry[name]; !ok {
+			if exitCode == 0 {
+				t.Fatalf("%v accepted without a backing allocator", name)
+			}
+		} else if exitCode != 0 {
+			t.Fatalf("replay against %v failed [exitCode=%v]", name, exitCode)
+		}
+	}
+
+	replaySettings.TraceFile = filepath.Join(t.TempDir(), "missing.bin")
+	replaySettings.Allocators = nil
+	if exitCode := runReplay(); exitCode == 0 {
+		t.Fatalf("missing trace file accepted")
+	}
+}
+
+func newHeapAllocator() *heapAllocator {
+	return &heapAllocator{
+		blocks: make(map[unsafe.Pointer][]byte),
+	}
+}
+
+func (a *heapAllocator) Alloc(size uintptr) unsafe.Pointer {
+	buf := make([]byte, size)
+	ptr := unsafe.Pointer(unsafe.SliceData(buf))
+	a.blocks[ptr] = buf
+	return ptr
+}
+
+func (a *heapAllocator) Free(ptr unsafe.Pointer) {
+	delete(a.blocks, ptr)
+}
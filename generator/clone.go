@@ -0,0 +1,209 @@
+package generator
+
+import (
+	"text/template"
+
+	parser "github.com/mxmauro/gofile-parser"
+)
+
+// -----------------------------------------------------------------------------
+
+func (sc *SaveContext) WriteStructCloners(st *Struct) error {
+	type CloneField struct {
+		Name               string
+		FuncName           string
+		SetFuncPrefix      string
+		TrySetFuncPrefix   string
+		TryReserveFuncName string
+		Opts               intFieldOptions
+		SrcItems           string
+		DestItems          string
+	}
+	type Clone struct {
+		TryNewFuncName string
+		StructName     string
+		AllocatorPkg   string
+		Fields         []CloneField
+	}
+
+	cl := Clone{
+		TryNewFuncName: funcNameForType(st.name, "tryNew", ""),
+		StructName:     st.name,
+		AllocatorPkg:   sc.allocatorPkg,
+		Fields:         make([]CloneField, 0),
+	}
+
+	for _, fld := range st.fields {
+		for _, name := range fld.names {
+			cf := CloneField{
+				Name:      name,
+				Opts:      fld.opts,
+				SrcItems:  "v." + name,
+				DestItems: "dst." + name,
+			}
+			cf.FuncName, cf.SetFuncPrefix, cf.TrySetFuncPrefix = fieldFuncNames(name)
+			if parser.IsPublic(name) {
+				cf.TryReserveFuncName = "TryReserve" + cf.FuncName
+			} else {
+				cf.TryReserveFuncName = "tryReserve" + cf.FuncName
+			}
+			if fld.opts.ArraySlice != nil && fld.opts.IsPointer {
+				cf.SrcItems = "(*v." + name + ")"
+				cf.DestItems = "(*dst." + name + ")"
+			}
+
+			cl.Fields = append(cl.Fields, cf)
+		}
+	}
+
+	funcMap := template.FuncMap{
+		"isSlice": func(s *string) bool {
+			return s != nil && len(*s) == 0
+		},
+		"isArrayOrSlice": func(s *string) bool {
+			return s != nil
+		},
+	}
+
+	err := sc.WriteTemplate("StructCloners", `
+// Clone creates a deep copy of the object using the given allocator and returns a pointer to it
+func (v *{{.StructName}}) Clone(alloc {{.AllocatorPkg}}.Allocator) *{{.StructName}} {
+	dst, err := v.TryClone(alloc)
+	if err != nil {
+		panic("cannot allocate memory for {{$.StructName}}")
+	}
+	return dst
+}
+
+// TryClone creates a deep copy of the object using the given allocator and returns a pointer to it or an error if
+// memory cannot be allocated
+func (v *{{.StructName}}) TryClone(alloc {{.AllocatorPkg}}.Allocator) (*{{.StructName}}, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	dst, err := {{.TryNewFuncName}}(alloc)
+	if err != nil {
+		return nil, err
+	}
+	err = v.TryCloneInto(dst)
+	if err != nil {
+		dst.Free()
+		return nil, err
+	}
+	return dst, nil
+}
+
+// CloneInto replaces the contents of dst with a deep copy of the object using the allocator of dst
+func (v *{{.StructName}}) CloneInto(dst *{{.StructName}}) {
+	if err := v.TryCloneInto(dst); err != nil {
+		panic("{{$.StructName}}::CloneInto: " + err.Error())
+	}
+}
+
+// TryCloneInto replaces the contents of dst with a deep copy of the object using the allocator of dst. On error, dst
+// can be partially updated but it remains valid
+func (v *{{.StructName}}) TryCloneInto(dst *{{.StructName}}) error {
+	if dst == v {
+		return nil
+	}
+{{- range $fldIdx, $fld := .Fields}}
+	{{- if and $fld.Opts.IsPointer (not (isArrayOrSlice $fld.Opts.ArraySlice)) }}
+		{{- if $fld.Opts.IsNative }}
+			if err := dst.{{$fld.TrySetFuncPrefix}}{{$fld.FuncName}}(v.{{$fld.Name}}); err != nil {
+				return err
+			}
+		{{- else }}
+			if v.{{$fld.Name}} != nil {
+				value, err := v.{{$fld.Name}}.TryClone(dst.__alloc)
+				if err != nil {
+					return err
+				}
+				dst.{{$fld.SetFuncPrefix}}{{$fld.FuncName}}(value)
+			} else {
+				dst.{{$fld.SetFuncPrefix}}{{$fld.FuncName}}(nil)
+			}
+		{{- end }}
+	{{- else if isArrayOrSlice $fld.Opts.ArraySlice }}
+		{{- if $fld.Opts.IsPointer }}
+			if v.{{$fld.Name}} == nil {
+				{{- if isSlice $fld.Opts.ArraySlice }}
+					if err := dst.{{$fld.TrySetFuncPrefix}}{{$fld.FuncName}}Capacity(0, false); err != nil {
+						return err
+					}
+				{{- else }}
+					dst.{{$fld.SetFuncPrefix}}{{$fld.FuncName}}DestroyArray()
+				{{- end }}
+			} else {
+				{{- if isSlice $fld.Opts.ArraySlice }}
+					if err := dst.{{$fld.TrySetFuncPrefix}}{{$fld.FuncName}}Capacity(len(*v.{{$fld.Name}}), false); err != nil {
+						return err
+					}
+					if dst.{{$fld.Name}} == nil {
+						// An empty slice leaves a nil pointer, keep an empty one so the clone is Equal to v
+						if err := dst.{{$fld.TryReserveFuncName}}(0); err != nil {
+							return err
+						}
+					}
+				{{- else }}
+					if dst.{{$fld.Name}} == nil {
+						if err := dst.{{$fld.TrySetFuncPrefix}}{{$fld.FuncName}}CreateArray(); err != nil {
+							return err
+						}
+					}
+				{{- end }}
+		{{- else if isSlice $fld.Opts.ArraySlice }}
+			if err := dst.{{$fld.TrySetFuncPrefix}}{{$fld.FuncName}}Capacity(len(v.{{$fld.Name}}), false); err != nil {
+				return err
+			}
+		{{- end }}
+		for idx := range {{$fld.SrcItems}} {
+			{{- if $fld.Opts.IsArraySliceOfPointers }}
+				{{- if $fld.Opts.IsNative }}
+					if err := dst.{{$fld.TrySetFuncPrefix}}{{$fld.FuncName}}(idx, {{$fld.SrcItems}}[idx]); err != nil {
+						return err
+					}
+				{{- else }}
+					value, err := {{$fld.SrcItems}}[idx].TryClone(dst.__alloc)
+					if err != nil {
+						return err
+					}
+					dst.{{$fld.SetFuncPrefix}}{{$fld.FuncName}}(idx, value)
+				{{- end }}
+			{{- else if $fld.Opts.IsString }}
+				if err := dst.{{$fld.TrySetFuncPrefix}}{{$fld.FuncName}}(idx, {{$fld.SrcItems}}[idx]); err != nil {
+					return err
+				}
+			{{- else if $fld.Opts.IsNative }}
+				{{$fld.DestItems}}[idx] = {{$fld.SrcItems}}[idx]
+			{{- else }}
+				if err := {{$fld.SrcItems}}[idx].TryCloneInto(&{{$fld.DestItems}}[idx]); err != nil {
+					return err
+				}
+			{{- end }}
+		}
+		{{- if $fld.Opts.IsPointer }}
+			}
+		{{- end }}
+	{{- else if $fld.Opts.IsString }}
+		if err := dst.{{$fld.TrySetFuncPrefix}}{{$fld.FuncName}}(v.{{$fld.Name}}); err != nil {
+			return err
+		}
+	{{- else if $fld.Opts.IsNative }}
+		dst.{{$fld.Name}} = v.{{$fld.Name}}
+	{{- else }}
+		if err := v.{{$fld.Name}}.TryCloneInto(&dst.{{$fld.Name}}); err != nil {
+			return err
+		}
+	{{- end }}
+{{- end }}
+	return nil
+}
+`, funcMap, cl)
+	if err != nil {
+		return err
+	}
+
+	// Done
+	return nil
+}
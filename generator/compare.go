@@ -0,0 +1,200 @@
+package generator
+
+import (
+	"strings"
+	"text/template"
+)
+
+// -----------------------------------------------------------------------------
+
+func (sc *SaveContext) WriteStructComparers(st *Struct) error {
+	type CompareField struct {
+		Name       string
+		TypeName   string
+		Opts       intFieldOptions
+		Items      string
+		OtherItems string
+	}
+	type Compare struct {
+		StructName   string
+		AllocatorPkg string
+		Fields       []CompareField
+	}
+
+	cmp := Compare{
+		StructName:   st.name,
+		AllocatorPkg: sc.allocatorPkg,
+		Fields:       make([]CompareField, 0),
+	}
+
+	for _, fld := range st.fields {
+		for _, name := range fld.names {
+			cf := CompareField{
+				Name:       name,
+				TypeName:   fld.typeName,
+				Opts:       fld.opts,
+				Items:      "v." + name,
+				OtherItems: "other." + name,
+			}
+			if fld.opts.ArraySlice != nil && fld.opts.IsPointer {
+				cf.Items = "(*v." + name + ")"
+				cf.OtherItems = "(*other." + name + ")"
+			}
+
+			cmp.Fields = append(cmp.Fields, cf)
+		}
+	}
+
+	funcMap := template.FuncMap{
+		"isSlice": func(s *string) bool {
+			return s != nil && len(*s) == 0
+		},
+		"isArrayOrSlice": func(s *string) bool {
+			return s != nil
+		},
+		"hash": func(typeName string, exprParts ...string) string {
+			return hashNativeExpr(sc.allocatorPkg, typeName, strings.Join(exprParts, ""))
+		},
+	}
+
+	err := sc.WriteTemplate("StructComparers", `
+// Equal returns true if both objects have the same contents. Pointers, slices and nested objects are compared by
+// the contents they point to
+func (v *{{.StructName}}) Equal(other *{{.StructName}}) bool {
+	if v == nil || other == nil {
+		return v == other
+	}
+{{- range $fldIdx, $fld := .Fields}}
+	{{- if and $fld.Opts.IsPointer (not (isArrayOrSlice $fld.Opts.ArraySlice)) }}
+		{{- if $fld.Opts.IsNative }}
+			if (v.{{$fld.Name}} == nil) != (other.{{$fld.Name}} == nil) || (v.{{$fld.Name}} != nil && *v.{{$fld.Name}} != *other.{{$fld.Name}}) {
+				return false
+			}
+		{{- else }}
+			if !v.{{$fld.Name}}.Equal(other.{{$fld.Name}}) {
+				return false
+			}
+		{{- end }}
+	{{- else if isArrayOrSlice $fld.Opts.ArraySlice }}
+		{{- if $fld.Opts.IsPointer }}
+			if (v.{{$fld.Name}} == nil) != (other.{{$fld.Name}} == nil) {
+				return false
+			}
+			if v.{{$fld.Name}} != nil {
+		{{- end }}
+		{{- if isSlice $fld.Opts.ArraySlice }}
+			if len({{$fld.Items}}) != len({{$fld.OtherItems}}) {
+				return false
+			}
+		{{- end }}
+		for idx := range {{$fld.Items}} {
+			{{- if $fld.Opts.IsArraySliceOfPointers }}
+				{{- if $fld.Opts.IsNative }}
+					if ({{$fld.Items}}[idx] == nil) != ({{$fld.OtherItems}}[idx] == nil) || ({{$fld.Items}}[idx] != nil && *{{$fld.Items}}[idx] != *{{$fld.OtherItems}}[idx]) {
+						return false
+					}
+				{{- else }}
+					if !{{$fld.Items}}[idx].Equal({{$fld.OtherItems}}[idx]) {
+						return false
+					}
+				{{- end }}
+			{{- else if $fld.Opts.IsNative }}
+				if {{$fld.Items}}[idx] != {{$fld.OtherItems}}[idx] {
+					return false
+				}
+			{{- else }}
+				if !{{$fld.Items}}[idx].Equal(&{{$fld.OtherItems}}[idx]) {
+					return false
+				}
+			{{- end }}
+		}
+		{{- if $fld.Opts.IsPointer }}
+			}
+		{{- end }}
+	{{- else if $fld.Opts.IsNative }}
+		if v.{{$fld.Name}} != other.{{$fld.Name}} {
+			return false
+		}
+	{{- else }}
+		if !v.{{$fld.Name}}.Equal(&other.{{$fld.Name}}) {
+			return false
+		}
+	{{- end }}
+{{- end }}
+	return true
+}
+
+// Hash returns a hash of the contents of the object mixed with seed. Objects that are Equal have the same hash
+func (v *{{.StructName}}) Hash(seed uint64) uint64 {
+	h := {{.AllocatorPkg}}.HashNil(seed, v == nil)
+	if v == nil {
+		return h
+	}
+{{- range $fldIdx, $fld := .Fields}}
+	{{- if and $fld.Opts.IsPointer (not (isArrayOrSlice $fld.Opts.ArraySlice)) }}
+		{{- if $fld.Opts.IsNative }}
+			h = {{$.AllocatorPkg}}.HashNil(h, v.{{$fld.Name}} == nil)
+			if v.{{$fld.Name}} != nil {
+				h = {{hash $fld.TypeName "*v." $fld.Name}}
+			}
+		{{- else }}
+			h = v.{{$fld.Name}}.Hash(h)
+		{{- end }}
+	{{- else if isArrayOrSlice $fld.Opts.ArraySlice }}
+		{{- if $fld.Opts.IsPointer }}
+			h = {{$.AllocatorPkg}}.HashNil(h, v.{{$fld.Name}} == nil)
+			if v.{{$fld.Name}} != nil {
+		{{- end }}
+		{{- if isSlice $fld.Opts.ArraySlice }}
+			h = {{$.AllocatorPkg}}.HashUint64(h, uint64(len({{$fld.Items}})))
+		{{- end }}
+		for idx := range {{$fld.Items}} {
+			{{- if $fld.Opts.IsArraySliceOfPointers }}
+				{{- if $fld.Opts.IsNative }}
+					h = {{$.AllocatorPkg}}.HashNil(h, {{$fld.Items}}[idx] == nil)
+					if {{$fld.Items}}[idx] != nil {
+						h = {{hash $fld.TypeName "*" $fld.Items "[idx]"}}
+					}
+				{{- else }}
+					h = {{$fld.Items}}[idx].Hash(h)
+				{{- end }}
+			{{- else if $fld.Opts.IsNative }}
+				h = {{hash $fld.TypeName $fld.Items "[idx]"}}
+			{{- else }}
+				h = {{$fld.Items}}[idx].Hash(h)
+			{{- end }}
+		}
+		{{- if $fld.Opts.IsPointer }}
+			}
+		{{- end }}
+	{{- else if $fld.Opts.IsNative }}
+		h = {{hash $fld.TypeName "v." $fld.Name}}
+	{{- else }}
+		h = v.{{$fld.Name}}.Hash(h)
+	{{- end }}
+{{- end }}
+	return h
+}
+`, funcMap, cmp)
+	if err != nil {
+		return err
+	}
+
+	// Done
+	return nil
+}
+
+// hashNativeExpr returns the expression that mixes a value of a native type into the hash h
+func hashNativeExpr(allocatorPkg string, typeName string, expr string) string {
+	switch typeName {
+	case "string":
+		return allocatorPkg + ".HashString(h, " + expr + ")"
+	case "bool":
+		return allocatorPkg + ".HashBool(h, " + expr + ")"
+	case "float", "float32", "float64":
+		return allocatorPkg + ".HashFloat64(h, float64(" + expr + "))"
+	case "complex64", "complex128":
+		return allocatorPkg + ".HashFloat64(" + allocatorPkg + ".HashFloat64(h, float64(real(" + expr + "))), float64(imag(" + expr + ")))"
+	}
+	return allocatorPkg + ".HashUint64(h, uint64(" + expr + "))"
+}
@@ -0,0 +1,301 @@
+package generator
+
+import (
+	"text/template"
+
+	parser "github.com/mxmauro/gofile-parser"
+)
+
+// -----------------------------------------------------------------------------
+
+func (sc *SaveContext) WriteStructConverters(st *Struct) error {
+	type ConvertField struct {
+		Name                  string
+		FuncName              string
+		SetFuncPrefix         string
+		TrySetFuncPrefix      string
+		ManagedTypeName       string
+		TryNewFromFuncName    string
+		TryReserveFuncName    string
+		Opts                  intFieldOptions
+		SrcItems              string
+		DestItems             string
+		UnmanagedItems        string
+		ManagedItems          string
+		ManagedItemsPrefixMod string
+	}
+	type Convert struct {
+		NewFromFuncName    string
+		TryNewFromFuncName string
+		TryNewFuncName     string
+		StructName         string
+		ManagedStructName  string
+		AllocatorPkg       string
+		Fields             []ConvertField
+	}
+
+	conv := Convert{
+		NewFromFuncName:    funcNameForType(st.name, "new", "From"),
+		TryNewFromFuncName: funcNameForType(st.name, "tryNew", "From"),
+		TryNewFuncName:     funcNameForType(st.name, "tryNew", ""),
+		StructName:         st.name,
+		ManagedStructName:  st.managedName,
+		AllocatorPkg:       sc.allocatorPkg,
+		Fields:             make([]ConvertField, 0),
+	}
+
+	for _, fld := range st.fields {
+		for _, name := range fld.names {
+			cf := ConvertField{
+				Name:            name,
+				ManagedTypeName: fld.managedTypeName,
+				Opts:            fld.opts,
+				SrcItems:        "src." + name,
+				DestItems:       "v." + name,
+				UnmanagedItems:  "v." + name,
+				ManagedItems:    "dst." + name,
+			}
+			cf.FuncName, cf.SetFuncPrefix, cf.TrySetFuncPrefix = fieldFuncNames(name)
+			if parser.IsPublic(name) {
+				cf.TryReserveFuncName = "TryReserve" + cf.FuncName
+			} else {
+				cf.TryReserveFuncName = "tryReserve" + cf.FuncName
+			}
+			if !fld.opts.IsNative {
+				cf.TryNewFromFuncName = funcNameForType(fld.typeName, "tryNew", "From")
+			}
+			if fld.opts.ArraySlice != nil {
+				cf.ManagedItemsPrefixMod = "[" + (*fld.opts.ArraySlice) + "]"
+				if fld.opts.IsArraySliceOfPointers {
+					cf.ManagedItemsPrefixMod += "*"
+				}
+				if fld.opts.IsPointer {
+					// Elements are accessed through the pointer and the managed copy is built in a local variable
+					cf.SrcItems = "(*src." + name + ")"
+					cf.DestItems = "(*v." + name + ")"
+					cf.UnmanagedItems = "(*v." + name + ")"
+					cf.ManagedItems = "items"
+				}
+			}
+
+			conv.Fields = append(conv.Fields, cf)
+		}
+	}
+
+	funcMap := template.FuncMap{
+		"isSlice": func(s *string) bool {
+			return s != nil && len(*s) == 0
+		},
+		"isArray": func(s *string) bool {
+			return s != nil && len(*s) > 0
+		},
+		"isArrayOrSlice": func(s *string) bool {
+			return s != nil
+		},
+	}
+
+	err := sc.WriteTemplate("StructConverters", `
+// {{.NewFromFuncName}} creates a new {{.StructName}} object with a deep copy of src and returns a pointer to it
+func {{.NewFromFuncName}}(alloc {{.AllocatorPkg}}.Allocator, src *{{.ManagedStructName}}) *{{.StructName}} {
+	v, err := {{.TryNewFromFuncName}}(alloc, src)
+	if err != nil {
+		panic("cannot allocate memory for {{$.StructName}}")
+	}
+	return v
+}
+
+// {{.TryNewFromFuncName}} creates a new {{.StructName}} object with a deep copy of src and returns a pointer to
+// it or an error if memory cannot be allocated
+func {{.TryNewFromFuncName}}(alloc {{.AllocatorPkg}}.Allocator, src *{{.ManagedStructName}}) (*{{.StructName}}, error) {
+	v, err := {{.TryNewFuncName}}(alloc)
+	if err != nil {
+		return nil, err
+	}
+	err = v.FromManaged(src)
+	if err != nil {
+		v.Free()
+		return nil, err
+	}
+	return v, nil
+}
+
+// FromManaged replaces the contents of the object with a deep copy of src. Empty slice fields become nil, but
+// pointers to empty slices are kept. On error, the object can be partially updated but it remains valid
+func (v *{{.StructName}}) FromManaged(src *{{.ManagedStructName}}) error {
+{{- range $fldIdx, $fld := .Fields}}
+	{{- if and $fld.Opts.IsPointer (not (isArrayOrSlice $fld.Opts.ArraySlice)) }}
+		{{- if $fld.Opts.IsNative }}
+			if err := v.{{$fld.TrySetFuncPrefix}}{{$fld.FuncName}}(src.{{$fld.Name}}); err != nil {
+				return err
+			}
+		{{- else }}
+			if src.{{$fld.Name}} != nil {
+				value, err := {{$fld.TryNewFromFuncName}}(v.__alloc, src.{{$fld.Name}})
+				if err != nil {
+					return err
+				}
+				v.{{$fld.SetFuncPrefix}}{{$fld.FuncName}}(value)
+			} else {
+				v.{{$fld.SetFuncPrefix}}{{$fld.FuncName}}(nil)
+			}
+		{{- end }}
+	{{- else if isArrayOrSlice $fld.Opts.ArraySlice }}
+		{{- if $fld.Opts.IsPointer }}
+			if src.{{$fld.Name}} == nil {
+				{{- if isSlice $fld.Opts.ArraySlice }}
+					if err := v.{{$fld.TrySetFuncPrefix}}{{$fld.FuncName}}Capacity(0, false); err != nil {
+						return err
+					}
+				{{- else }}
+					v.{{$fld.SetFuncPrefix}}{{$fld.FuncName}}DestroyArray()
+				{{- end }}
+			} else {
+				{{- if isSlice $fld.Opts.ArraySlice }}
+					if err := v.{{$fld.TrySetFuncPrefix}}{{$fld.FuncName}}Capacity(len(*src.{{$fld.Name}}), false); err != nil {
+						return err
+					}
+					if v.{{$fld.Name}} == nil {
+						// An empty slice leaves a nil pointer, keep an empty one so src is not turned into nil
+						if err := v.{{$fld.TryReserveFuncName}}(0); err != nil {
+							return err
+						}
+					}
+				{{- else }}
+					if v.{{$fld.Name}} == nil {
+						if err := v.{{$fld.TrySetFuncPrefix}}{{$fld.FuncName}}CreateArray(); err != nil {
+							return err
+						}
+					}
+				{{- end }}
+		{{- else if isSlice $fld.Opts.ArraySlice }}
+			if err := v.{{$fld.TrySetFuncPrefix}}{{$fld.FuncName}}Capacity(len(src.{{$fld.Name}}), false); err != nil {
+				return err
+			}
+		{{- end }}
+		for idx := range {{$fld.SrcItems}} {
+			{{- if $fld.Opts.IsArraySliceOfPointers }}
+				{{- if $fld.Opts.IsNative }}
+					if err := v.{{$fld.TrySetFuncPrefix}}{{$fld.FuncName}}(idx, {{$fld.SrcItems}}[idx]); err != nil {
+						return err
+					}
+				{{- else }}
+					if {{$fld.SrcItems}}[idx] != nil {
+						value, err := {{$fld.TryNewFromFuncName}}(v.__alloc, {{$fld.SrcItems}}[idx])
+						if err != nil {
+							return err
+						}
+						v.{{$fld.SetFuncPrefix}}{{$fld.FuncName}}(idx, value)
+					} else {
+						v.{{$fld.SetFuncPrefix}}{{$fld.FuncName}}(idx, nil)
+					}
+				{{- end }}
+			{{- else if $fld.Opts.IsString }}
+				if err := v.{{$fld.TrySetFuncPrefix}}{{$fld.FuncName}}(idx, {{$fld.SrcItems}}[idx]); err != nil {
+					return err
+				}
+			{{- else if $fld.Opts.IsNative }}
+				{{$fld.DestItems}}[idx] = {{$fld.SrcItems}}[idx]
+			{{- else }}
+				if err := {{$fld.DestItems}}[idx].FromManaged(&{{$fld.SrcItems}}[idx]); err != nil {
+					return err
+				}
+			{{- end }}
+		}
+		{{- if $fld.Opts.IsPointer }}
+			}
+		{{- end }}
+	{{- else if $fld.Opts.IsString }}
+		if err := v.{{$fld.TrySetFuncPrefix}}{{$fld.FuncName}}(src.{{$fld.Name}}); err != nil {
+			return err
+		}
+	{{- else if $fld.Opts.IsNative }}
+		v.{{$fld.Name}} = src.{{$fld.Name}}
+	{{- else }}
+		if err := v.{{$fld.Name}}.FromManaged(&src.{{$fld.Name}}); err != nil {
+			return err
+		}
+	{{- end }}
+{{- end }}
+	return nil
+}
+
+// ToManaged returns a deep copy of the object allocated in the Go heap. Unmanaged slices cannot be empty and
+// non-nil, so empty slice fields are returned as nil. Pointers to empty slices are kept
+func (v *{{.StructName}}) ToManaged() *{{.ManagedStructName}} {
+	if v == nil {
+		return nil
+	}
+
+	dst := &{{.ManagedStructName}}{}
+{{- range $fldIdx, $fld := .Fields}}
+	{{- if and $fld.Opts.IsPointer (not (isArrayOrSlice $fld.Opts.ArraySlice)) }}
+		{{- if $fld.Opts.IsNative }}
+			if v.{{$fld.Name}} != nil {
+				{{- if $fld.Opts.IsString }}
+					value := strings.Clone(*v.{{$fld.Name}})
+				{{- else }}
+					value := *v.{{$fld.Name}}
+				{{- end }}
+				dst.{{$fld.Name}} = &value
+			}
+		{{- else }}
+			dst.{{$fld.Name}} = v.{{$fld.Name}}.ToManaged()
+		{{- end }}
+	{{- else if isArrayOrSlice $fld.Opts.ArraySlice }}
+		{{- if $fld.Opts.IsPointer }}
+			if v.{{$fld.Name}} != nil {
+				{{- if isSlice $fld.Opts.ArraySlice }}
+					items := make({{$fld.ManagedItemsPrefixMod}}{{$fld.ManagedTypeName}}, len(*v.{{$fld.Name}}))
+				{{- else }}
+					var items {{$fld.ManagedItemsPrefixMod}}{{$fld.ManagedTypeName}}
+				{{- end }}
+		{{- else if isSlice $fld.Opts.ArraySlice }}
+			if v.{{$fld.Name}} != nil {
+				dst.{{$fld.Name}} = make({{$fld.ManagedItemsPrefixMod}}{{$fld.ManagedTypeName}}, len(v.{{$fld.Name}}))
+		{{- end }}
+		for idx := range {{$fld.UnmanagedItems}} {
+			{{- if $fld.Opts.IsArraySliceOfPointers }}
+				{{- if $fld.Opts.IsNative }}
+					if {{$fld.UnmanagedItems}}[idx] != nil {
+						{{- if $fld.Opts.IsString }}
+							value := strings.Clone(*{{$fld.UnmanagedItems}}[idx])
+						{{- else }}
+							value := *{{$fld.UnmanagedItems}}[idx]
+						{{- end }}
+						{{$fld.ManagedItems}}[idx] = &value
+					}
+				{{- else }}
+					{{$fld.ManagedItems}}[idx] = {{$fld.UnmanagedItems}}[idx].ToManaged()
+				{{- end }}
+			{{- else if $fld.Opts.IsString }}
+				{{$fld.ManagedItems}}[idx] = strings.Clone({{$fld.UnmanagedItems}}[idx])
+			{{- else if $fld.Opts.IsNative }}
+				{{$fld.ManagedItems}}[idx] = {{$fld.UnmanagedItems}}[idx]
+			{{- else }}
+				{{$fld.ManagedItems}}[idx] = *{{$fld.UnmanagedItems}}[idx].ToManaged()
+			{{- end }}
+		}
+		{{- if $fld.Opts.IsPointer }}
+				dst.{{$fld.Name}} = &items
+			}
+		{{- else if isSlice $fld.Opts.ArraySlice }}
+			}
+		{{- end }}
+	{{- else if $fld.Opts.IsString }}
+		dst.{{$fld.Name}} = strings.Clone(v.{{$fld.Name}})
+	{{- else if $fld.Opts.IsNative }}
+		dst.{{$fld.Name}} = v.{{$fld.Name}}
+	{{- else }}
+		dst.{{$fld.Name}} = *v.{{$fld.Name}}.ToManaged()
+	{{- end }}
+{{- end }}
+	return dst
+}
+`, funcMap, conv)
+	if err != nil {
+		return err
+	}
+
+	// Done
+	return nil
+}
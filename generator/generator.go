@@ -28,6 +28,7 @@ type Struct struct {
 type Field struct {
 	names             []string
 	typeName          string
+	managedTypeName   string
 	typeNamePrefixMod string
 	tags              string
 	opts              intFieldOptions
@@ -151,6 +152,7 @@ func (gs *Struct) AddField(names []string, typeName string, tags parser.ParsedTa
 		ArraySlice:             opts.ArraySlice,
 		IsArraySliceOfPointers: opts.IsArraySliceOfPointers,
 	}
+	managedTypeName := typeName
 	if !opts.IsNative {
 		typeName = UnmanagedName(typeName)
 	}
@@ -176,6 +178,7 @@ func (gs *Struct) AddField(names []string, typeName string, tags parser.ParsedTa
 	gs.fields = append(gs.fields, Field{
 		names:             filteredNames,
 		typeName:          typeName,
+		managedTypeName:   managedTypeName,
 		typeNamePrefixMod: typeNamePrefixMod,
 		tags:              finalTags,
 		opts:              iOpts,
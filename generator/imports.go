@@ -12,16 +12,14 @@ func (sc *SaveContext) WriteImports() error {
 	// sc.allocatorPkg = sc.gen.NextId() + "_alloc"
 	sc.allocatorPkg = "allocator"
 
-	sc.WriteLine("import (")
-	sc.WriteLine("\"unsafe\"")
-	sc.WriteLine("")
-	// sc.WriteLine("%v \"github.com/mxmauro/unmanagedgen/allocator\"", sc.allocatorPkg)
-	sc.WriteLine("\"github.com/mxmauro/unmanagedgen/allocator\"")
-
 	// Create a list of used package names
 	pkgNames := make([]string, 0)
+	haveStrings := false
 	for _, st := range sc.gen.structs {
 		for _, fld := range st.fields {
+			if fld.opts.IsString {
+				haveStrings = true
+			}
 			pkgName, _ := parser.GetIdentifierParts(fld.typeName)
 			if len(pkgName) > 0 {
 				found := false
@@ -38,6 +36,25 @@ func (sc *SaveContext) WriteImports() error {
 		}
 	}
 
+	// Converters copy strings to the Go heap with strings.Clone, unless a field type already brings the package
+	if haveStrings {
+		for _, name := range pkgNames {
+			if name == "strings" {
+				haveStrings = false
+				break
+			}
+		}
+	}
+
+	sc.WriteLine("import (")
+	if haveStrings {
+		sc.WriteLine("\"strings\"")
+	}
+	sc.WriteLine("\"unsafe\"")
+	sc.WriteLine("")
+	// sc.WriteLine("%v \"github.com/mxmauro/unmanagedgen/allocator\"", sc.allocatorPkg)
+	sc.WriteLine("\"github.com/mxmauro/unmanagedgen/allocator\"")
+
 	first := true
 	for _, pkgName := range pkgNames {
 		pkgImpIndex := -1
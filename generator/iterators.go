@@ -0,0 +1,160 @@
+package generator
+
+import (
+	parser "github.com/mxmauro/gofile-parser"
+)
+
+// -----------------------------------------------------------------------------
+
+func (sc *SaveContext) WriteStructIterators(st *Struct) error {
+	type IteratorField struct {
+		Name         string
+		MethodNames  map[string]string
+		ElemTypeName string
+		ItemTypeName string
+		ItemsByPtr   bool
+		Opts         intFieldOptions
+		Items        string
+		ItemsAsSlice string
+	}
+	type Iterators struct {
+		StructName   string
+		AllocatorPkg string
+		Fields       []IteratorField
+	}
+
+	it := Iterators{
+		StructName:   st.name,
+		AllocatorPkg: sc.allocatorPkg,
+		Fields:       make([]IteratorField, 0),
+	}
+
+	for _, fld := range st.fields {
+		if fld.opts.ArraySlice == nil {
+			continue
+		}
+
+		elemTypeName := fld.typeName
+		if fld.opts.IsArraySliceOfPointers {
+			elemTypeName = "*" + elemTypeName
+		}
+
+		// Unmanaged objects are handed out by pointer, so callers never hold a copy that owns their fields
+		itemsByPtr := !fld.opts.IsNative && !fld.opts.IsArraySliceOfPointers
+		itemTypeName := elemTypeName
+		if itemsByPtr {
+			itemTypeName = "*" + elemTypeName
+		}
+
+		for _, name := range fld.names {
+			funcName, _, _ := fieldFuncNames(name)
+
+			itf := IteratorField{
+				Name:         name,
+				MethodNames:  make(map[string]string),
+				ElemTypeName: elemTypeName,
+				ItemTypeName: itemTypeName,
+				ItemsByPtr:   itemsByPtr,
+				Opts:         fld.opts,
+				Items:        "v." + name,
+			}
+			for prefix, suffix := range map[string]string{"All": "", "Backward": "", "Sort": "Func", "BinarySearch": "Func"} {
+				if parser.IsPublic(name) {
+					itf.MethodNames[prefix] = prefix + funcName + suffix
+				} else {
+					itf.MethodNames[prefix] = lowerFirstLetter(prefix) + funcName + suffix
+				}
+			}
+			if fld.opts.IsPointer {
+				itf.Items = "(*v." + name + ")"
+			}
+			itf.ItemsAsSlice = itf.Items
+			if len(*fld.opts.ArraySlice) > 0 {
+				itf.ItemsAsSlice += "[:]"
+			}
+
+			it.Fields = append(it.Fields, itf)
+		}
+	}
+
+	err := sc.WriteTemplate("StructIterators", `
+{{- range $fldIdx, $fld := .Fields}}
+{{- $items := $fld.Items }}
+
+// {{index $fld.MethodNames "All"}} returns an iterator over the positions and elements of {{$fld.Name}}. It is an
+// iter.Seq2, usable with range if the calling module declares Go 1.23 or later
+func (v *{{$.StructName}}) {{index $fld.MethodNames "All"}}() func(yield func(int, {{$fld.ItemTypeName}}) bool) {
+	return func(yield func(int, {{$fld.ItemTypeName}}) bool) {
+		{{- if $fld.Opts.IsPointer }}
+			if v.{{$fld.Name}} == nil {
+				return
+			}
+		{{- end }}
+		for idx := range {{$items}} {
+			if !yield(idx, {{if $fld.ItemsByPtr}}&{{end}}{{$items}}[idx]) {
+				return
+			}
+		}
+	}
+}
+
+// {{index $fld.MethodNames "Backward"}} returns an iterator over the positions and elements of {{$fld.Name}} in
+// reverse order. It is an iter.Seq2, usable with range if the calling module declares Go 1.23 or later
+func (v *{{$.StructName}}) {{index $fld.MethodNames "Backward"}}() func(yield func(int, {{$fld.ItemTypeName}}) bool) {
+	return func(yield func(int, {{$fld.ItemTypeName}}) bool) {
+		{{- if $fld.Opts.IsPointer }}
+			if v.{{$fld.Name}} == nil {
+				return
+			}
+		{{- end }}
+		for idx := len({{$items}}) - 1; idx >= 0; idx-- {
+			if !yield(idx, {{if $fld.ItemsByPtr}}&{{end}}{{$items}}[idx]) {
+				return
+			}
+		}
+	}
+}
+
+// {{index $fld.MethodNames "Sort"}} sorts the elements of {{$fld.Name}} in ascending order as determined by cmp.
+// Elements are moved, not copied, so each one keeps the ownership of its data
+func (v *{{$.StructName}}) {{index $fld.MethodNames "Sort"}}(cmp func(a, b {{$fld.ItemTypeName}}) int) {
+	{{- if $fld.Opts.IsPointer }}
+		if v.{{$fld.Name}} == nil {
+			return
+		}
+	{{- end }}
+	{{- if $fld.ItemsByPtr }}
+		{{$.AllocatorPkg}}.SortPtrFunc({{$fld.ItemsAsSlice}}, cmp)
+	{{- else }}
+		{{$.AllocatorPkg}}.SortPtrFunc({{$fld.ItemsAsSlice}}, func(a, b *{{$fld.ElemTypeName}}) int {
+			return cmp(*a, *b)
+		})
+	{{- end }}
+}
+
+// {{index $fld.MethodNames "BinarySearch"}} searches for target in {{$fld.Name}}, which must be sorted in ascending
+// order as determined by cmp, and returns the position where it is found, or where it would be inserted, and
+// whether it was found
+func (v *{{$.StructName}}) {{index $fld.MethodNames "BinarySearch"}}(target {{$fld.ItemTypeName}}, cmp func(a, b {{$fld.ItemTypeName}}) int) (int, bool) {
+	{{- if $fld.Opts.IsPointer }}
+		if v.{{$fld.Name}} == nil {
+			return 0, false
+		}
+	{{- end }}
+	{{- if $fld.ItemsByPtr }}
+		return {{$.AllocatorPkg}}.BinarySearchPtrFunc({{$fld.ItemsAsSlice}}, target, cmp)
+	{{- else }}
+		return {{$.AllocatorPkg}}.BinarySearchPtrFunc({{$fld.ItemsAsSlice}}, &target, func(a, b *{{$fld.ElemTypeName}}) int {
+			return cmp(*a, *b)
+		})
+	{{- end }}
+}
+{{- end }}
+`, nil, it)
+	if err != nil {
+		return err
+	}
+
+	// Done
+	return nil
+}
@@ -0,0 +1,341 @@
+package generator
+
+import (
+	"text/template"
+
+	parser "github.com/mxmauro/gofile-parser"
+)
+
+// -----------------------------------------------------------------------------
+
+// minSliceCapacity is the capacity given to a slice field the first time it grows through Append or Insert
+const minSliceCapacity = 4
+
+// -----------------------------------------------------------------------------
+
+func (sc *SaveContext) WriteStructSliceHelpers(st *Struct) error {
+	type SliceField struct {
+		Name                  string
+		FuncName              string
+		SetFuncPrefix         string
+		TrySetFuncPrefix      string
+		MethodNames           map[string]string
+		ElemTypeName          string
+		Opts                  intFieldOptions
+		Items                 string
+		FriendlyArrayTypeName string
+	}
+	type SliceHelpers struct {
+		StructName       string
+		AllocatorPkg     string
+		MinSliceCapacity int
+		Fields           []SliceField
+	}
+
+	sh := SliceHelpers{
+		StructName:       st.name,
+		AllocatorPkg:     sc.allocatorPkg,
+		MinSliceCapacity: minSliceCapacity,
+		Fields:           make([]SliceField, 0),
+	}
+
+	for _, fld := range st.fields {
+		if fld.opts.ArraySlice == nil || len(*fld.opts.ArraySlice) > 0 {
+			continue
+		}
+
+		elemTypeName := fld.typeName
+		if fld.opts.IsArraySliceOfPointers {
+			elemTypeName = "*" + elemTypeName
+		}
+
+		for _, name := range fld.names {
+			sf := SliceField{
+				Name:                  name,
+				MethodNames:           make(map[string]string),
+				ElemTypeName:          elemTypeName,
+				Opts:                  fld.opts,
+				Items:                 "v." + name,
+				FriendlyArrayTypeName: friendlyArrayTypeName(elemTypeName),
+			}
+			sf.FuncName, sf.SetFuncPrefix, sf.TrySetFuncPrefix = fieldFuncNames(name)
+			for _, prefix := range []string{"Reserve", "TryReserve", "Append", "TryAppend", "Insert", "TryInsert", "Remove", "Truncate"} {
+				if parser.IsPublic(name) {
+					sf.MethodNames[prefix] = prefix + sf.FuncName
+				} else {
+					sf.MethodNames[prefix] = lowerFirstLetter(prefix) + sf.FuncName
+				}
+			}
+			if fld.opts.IsPointer {
+				sf.Items = "(*v." + name + ")"
+			}
+
+			sh.Fields = append(sh.Fields, sf)
+		}
+	}
+
+	funcMap := template.FuncMap{
+		"mustFreeElements": func(opts intFieldOptions) bool {
+			return opts.IsArraySliceOfPointers || !opts.IsNative || opts.IsString
+		},
+	}
+
+	err := sc.WriteTemplate("StructSliceHelpers", `
+{{- range $fldIdx, $fld := .Fields}}
+{{- $items := $fld.Items }}
+
+// {{index $fld.MethodNames "Reserve"}} makes sure {{$fld.Name}} can hold capacity elements without being reallocated
+func (v *{{$.StructName}}) {{index $fld.MethodNames "Reserve"}}(capacity int) {
+	if err := v.{{index $fld.MethodNames "TryReserve"}}(capacity); err != nil {
+		panic("{{$.StructName}}::{{index $fld.MethodNames "Reserve"}}: " + err.Error())
+	}
+}
+
+func (v *{{$.StructName}}) {{index $fld.MethodNames "TryReserve"}}(capacity int) error {
+	{{- if $fld.Opts.IsPointer }}
+		if v.{{$fld.Name}} != nil && cap(*v.{{$fld.Name}}) >= capacity {
+			return nil
+		}
+	{{- else }}
+		if cap(v.{{$fld.Name}}) >= capacity {
+			return nil
+		}
+	{{- end }}
+	return v.grow{{$fld.FuncName}}(capacity)
+}
+
+// {{index $fld.MethodNames "Append"}} adds value at the end of {{$fld.Name}}, growing its capacity if needed
+{{- if not $fld.Opts.IsNative }}. The object
+// takes the ownership of value, so the caller must not free it
+{{- end }}
+func (v *{{$.StructName}}) {{index $fld.MethodNames "Append"}}(value {{$fld.ElemTypeName}}) {
+	if err := v.{{index $fld.MethodNames "TryAppend"}}(value); err != nil {
+		panic("{{$.StructName}}::{{index $fld.MethodNames "Append"}}: " + err.Error())
+	}
+}
+
+func (v *{{$.StructName}}) {{index $fld.MethodNames "TryAppend"}}(value {{$fld.ElemTypeName}}) error {
+	{{- if $fld.Opts.IsPointer }}
+		idx := 0
+		if v.{{$fld.Name}} != nil {
+			idx = len(*v.{{$fld.Name}})
+		}
+		return v.{{index $fld.MethodNames "TryInsert"}}(idx, value)
+	{{- else }}
+		return v.{{index $fld.MethodNames "TryInsert"}}(len(v.{{$fld.Name}}), value)
+	{{- end }}
+}
+
+// {{index $fld.MethodNames "Insert"}} inserts value at position idx of {{$fld.Name}}, moving the following elements
+// and growing its capacity if needed
+{{- if not $fld.Opts.IsNative }}. The object takes the ownership of value, so the caller must not free it
+{{- end }}
+func (v *{{$.StructName}}) {{index $fld.MethodNames "Insert"}}(idx int, value {{$fld.ElemTypeName}}) {
+	if err := v.{{index $fld.MethodNames "TryInsert"}}(idx, value); err != nil {
+		panic("{{$.StructName}}::{{index $fld.MethodNames "Insert"}}: " + err.Error())
+	}
+}
+
+func (v *{{$.StructName}}) {{index $fld.MethodNames "TryInsert"}}(idx int, value {{$fld.ElemTypeName}}) error {
+	{{- if $fld.Opts.IsPointer }}
+		sliceLen := 0
+		if v.{{$fld.Name}} != nil {
+			sliceLen = len(*v.{{$fld.Name}})
+		}
+	{{- else }}
+		sliceLen := len(v.{{$fld.Name}})
+	{{- end }}
+	// Check the position before growing, so an invalid call leaves the slice untouched
+	if idx < 0 || idx > sliceLen {
+		return {{$.AllocatorPkg}}.ErrIndexOutOfRange
+	}
+	{{- if $fld.Opts.IsPointer }}
+		if v.{{$fld.Name}} == nil || sliceLen == cap(*v.{{$fld.Name}}) {
+	{{- else }}
+		if sliceLen == cap(v.{{$fld.Name}}) {
+	{{- end }}
+		newCap := sliceLen * 2
+		if newCap < {{$.MinSliceCapacity}} {
+			newCap = {{$.MinSliceCapacity}}
+		}
+		if err := v.grow{{$fld.FuncName}}(newCap); err != nil {
+			return err
+		}
+	}
+
+	// Make room for the new element. Elements are moved, so they keep the ownership of their data
+	{{$items}} = {{$items}}[:sliceLen+1]
+	copy({{$items}}[idx+1:], {{$items}}[idx:sliceLen])
+	clear({{$items}}[idx : idx+1])
+
+	{{- if and $fld.Opts.IsNative (or $fld.Opts.IsString $fld.Opts.IsArraySliceOfPointers) }}
+		if err := v.{{$fld.TrySetFuncPrefix}}{{$fld.FuncName}}(idx, value); err != nil {
+			// Undo
+			copy({{$items}}[idx:], {{$items}}[idx+1:])
+			clear({{$items}}[sliceLen:])
+			{{$items}} = {{$items}}[:sliceLen]
+			return err
+		}
+	{{- else }}
+		{{$items}}[idx] = value
+	{{- end }}
+	return nil
+}
+
+// {{index $fld.MethodNames "Remove"}} frees the element at position idx of {{$fld.Name}} and moves the following
+// ones. The capacity is kept
+func (v *{{$.StructName}}) {{index $fld.MethodNames "Remove"}}(idx int) {
+	// assert {{if $fld.Opts.IsPointer}}v.{{$fld.Name}} != nil && {{end}}idx >= 0 && idx < len({{$items}})
+	sliceLen := len({{$items}})
+	{{- if mustFreeElements $fld.Opts }}
+
+		{{- if $fld.Opts.IsNative }}
+		// Free the removed entry, unless the allocator releases memory in bulk
+		if !{{$.AllocatorPkg}}.FreeIsNoop(v.__alloc) {
+		{{- else }}
+		// Free the removed entry. Nested objects may use their own allocator, so they are always freed
+		{{- end }}
+			{{- if $fld.Opts.IsArraySliceOfPointers }}
+				v.{{$fld.SetFuncPrefix}}{{$fld.FuncName}}(idx, nil)
+			{{- else if $fld.Opts.IsNative }}
+				v.{{$fld.SetFuncPrefix}}{{$fld.FuncName}}(idx, unsafe.String(nil, 0))
+			{{- else }}
+				{{$items}}[idx].Free()
+			{{- end }}
+		{{- if $fld.Opts.IsNative }}
+		}
+		{{- end }}
+	{{- end }}
+	copy({{$items}}[idx:], {{$items}}[idx+1:])
+	clear({{$items}}[sliceLen-1:])
+	{{$items}} = {{$items}}[:sliceLen-1]
+}
+
+// {{index $fld.MethodNames "Truncate"}} frees the elements of {{$fld.Name}} from position sliceLen onwards. The
+// capacity is kept
+func (v *{{$.StructName}}) {{index $fld.MethodNames "Truncate"}}(sliceLen int) {
+	{{- if $fld.Opts.IsPointer }}
+		if v.{{$fld.Name}} == nil {
+			return
+		}
+	{{- end }}
+	oldSliceLen := len({{$items}})
+	// assert sliceLen >= 0 && sliceLen <= oldSliceLen
+	{{- if mustFreeElements $fld.Opts }}
+
+		{{- if $fld.Opts.IsNative }}
+		// Free dropped entries, unless the allocator releases memory in bulk
+		if !{{$.AllocatorPkg}}.FreeIsNoop(v.__alloc) {
+		{{- else }}
+		// Free dropped entries. Nested objects may use their own allocator, so they are always freed
+		{{- end }}
+			for idx := sliceLen; idx < oldSliceLen; idx++ {
+				{{- if $fld.Opts.IsArraySliceOfPointers }}
+					v.{{$fld.SetFuncPrefix}}{{$fld.FuncName}}(idx, nil)
+				{{- else if $fld.Opts.IsNative }}
+					v.{{$fld.SetFuncPrefix}}{{$fld.FuncName}}(idx, unsafe.String(nil, 0))
+				{{- else }}
+					{{$items}}[idx].Free()
+				{{- end }}
+			}
+		{{- if $fld.Opts.IsNative }}
+		}
+		{{- end }}
+	{{- end }}
+	clear({{$items}}[sliceLen:oldSliceLen])
+	{{$items}} = {{$items}}[:sliceLen]
+}
+
+// grow{{$fld.FuncName}} moves the elements of {{$fld.Name}} to a block that can hold capacity elements
+func (v *{{$.StructName}}) grow{{$fld.FuncName}}(capacity int) error {
+	{{- if $fld.Opts.IsPointer }}
+		if v.{{$fld.Name}} == nil {
+			newSlice, err := v.allocSlicePtr_{{$fld.FriendlyArrayTypeName}}(capacity)
+			if err != nil {
+				return err
+			}
+			*newSlice = (*newSlice)[:0]
+			v.{{$fld.Name}} = newSlice
+			return nil
+		}
+	{{- end }}
+	sliceLen := len({{$items}})
+
+	// Resize the current block if the allocator supports it
+	if sliceLen > 0 && unsafe.Alignof({{$items}}[0]) <= {{$.AllocatorPkg}}.DefaultAlignment {
+		if ra, ok := v.__alloc.({{$.AllocatorPkg}}.Reallocator); ok {
+			{{- if $fld.Opts.IsPointer }}
+				dataSize, overflow := {{$.AllocatorPkg}}.MulUintptr(unsafe.Sizeof({{$items}}[0]), uintptr(capacity))
+				if overflow || capacity < 0 {
+					return {{$.AllocatorPkg}}.ErrSizeOverflow
+				}
+				memSize, overflow := {{$.AllocatorPkg}}.AddUintptr(dataSize, unsafe.Sizeof({{$items}}))
+				if overflow {
+					return {{$.AllocatorPkg}}.ErrSizeOverflow
+				}
+				newSlice, err := v.reallocSlicePtr_{{$fld.FriendlyArrayTypeName}}(ra, v.{{$fld.Name}}, capacity, memSize)
+				if err != nil {
+					return err
+				}
+				*newSlice = (*newSlice)[:sliceLen]
+			{{- else }}
+				memSize, overflow := {{$.AllocatorPkg}}.MulUintptr(unsafe.Sizeof({{$items}}[0]), uintptr(capacity))
+				if overflow || capacity < 0 {
+					return {{$.AllocatorPkg}}.ErrSizeOverflow
+				}
+				newSlice, err := v.reallocSlice_{{$fld.FriendlyArrayTypeName}}(ra, v.{{$fld.Name}}, capacity, memSize)
+				if err != nil {
+					return err
+				}
+				newSlice = newSlice[:sliceLen]
+			{{- end }}
+			v.{{$fld.Name}} = newSlice
+			return nil
+		}
+	}
+
+	{{- if $fld.Opts.IsPointer }}
+		newSlice, err := v.allocSlicePtr_{{$fld.FriendlyArrayTypeName}}(capacity)
+		if err != nil {
+			return err
+		}
+		*newSlice = (*newSlice)[:sliceLen]
+	{{- else }}
+		newSlice, err := v.allocSlice_{{$fld.FriendlyArrayTypeName}}(capacity)
+		if err != nil {
+			return err
+		}
+		newSlice = newSlice[:sliceLen]
+	{{- end }}
+
+	// Move the elements, the new block takes the ownership of their data
+	if sliceLen > 0 {
+		memSize, _ := {{$.AllocatorPkg}}.MulUintptr(unsafe.Sizeof({{$items}}[0]), uintptr(sliceLen))
+		{{- if $fld.Opts.IsPointer }}
+			{{$.AllocatorPkg}}.CopyMem(unsafe.Pointer(unsafe.SliceData(*newSlice)), unsafe.Pointer(unsafe.SliceData({{$items}})), memSize)
+		{{- else }}
+			{{$.AllocatorPkg}}.CopyMem(unsafe.Pointer(unsafe.SliceData(newSlice)), unsafe.Pointer(unsafe.SliceData({{$items}})), memSize)
+		{{- end }}
+	}
+
+	// Free old block
+	{{- if $fld.Opts.IsPointer }}
+		v.__alloc.Free(unsafe.Pointer(v.{{$fld.Name}}))
+	{{- else }}
+		slicePtr := unsafe.SliceData(v.{{$fld.Name}})
+		if slicePtr != nil {
+			v.__alloc.Free(unsafe.Pointer(slicePtr))
+		}
+	{{- end }}
+	v.{{$fld.Name}} = newSlice
+	return nil
+}
+{{- end }}
+`, funcMap, sh)
+	if err != nil {
+		return err
+	}
+
+	// Done
+	return nil
+}
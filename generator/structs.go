@@ -5,8 +5,6 @@ import (
 	"strconv"
 	"strings"
 	"text/template"
-
-	parser "github.com/mxmauro/gofile-parser"
 )
 
 // -----------------------------------------------------------------------------
@@ -33,6 +31,41 @@ func (sc *SaveContext) WriteStructs() error {
 		}
 	}
 
+	for _, st := range sc.gen.structs {
+		err := sc.WriteStructSliceHelpers(st)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, st := range sc.gen.structs {
+		err := sc.WriteStructIterators(st)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, st := range sc.gen.structs {
+		err := sc.WriteStructConverters(st)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, st := range sc.gen.structs {
+		err := sc.WriteStructCloners(st)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, st := range sc.gen.structs {
+		err := sc.WriteStructComparers(st)
+		if err != nil {
+			return err
+		}
+	}
+
 	// Done
 	return nil
 }
@@ -96,6 +129,7 @@ func (sc *SaveContext) WriteStructAllocator(st *Struct) error {
 	}
 	type AllocNewFree struct {
 		NewFuncName       string
+		TryNewFuncName    string
 		StructName        string
 		ManagedStructName string
 		AllocatorPkg      string
@@ -112,11 +146,8 @@ func (sc *SaveContext) WriteStructAllocator(st *Struct) error {
 	}
 
 	// New method
-	if parser.IsPublic(st.name) {
-		allocNF.NewFuncName = "New" + st.name
-	} else {
-		allocNF.NewFuncName = "new" + capitalizeFirstLetter(st.name)
-	}
+	allocNF.NewFuncName = funcNameForType(st.name, "new", "")
+	allocNF.TryNewFuncName = funcNameForType(st.name, "tryNew", "")
 
 	for _, fld := range st.fields {
 		if fld.opts.IsString {
@@ -152,17 +183,25 @@ func (sc *SaveContext) WriteStructAllocator(st *Struct) error {
 	err := sc.WriteTemplate("StructAllocator", `
 // {{.NewFuncName}} creates a new {{.StructName}} object and returns a pointer to it
 func {{.NewFuncName}}(alloc {{.AllocatorPkg}}.Allocator) *{{.StructName}} {
+	v, err := {{.TryNewFuncName}}(alloc)
+	if err != nil {
+		panic("cannot allocate memory for {{$.StructName}}")
+	}
+	return v
+}
 
-	ptr := alloc.Alloc(unsafe.Sizeof({{.StructName}}{}))
+// {{.TryNewFuncName}} creates a new {{.StructName}} object and returns a pointer to it or an error if memory
+// cannot be allocated
+func {{.TryNewFuncName}}(alloc {{.AllocatorPkg}}.Allocator) (*{{.StructName}}, error) {
+	ptr := {{.AllocatorPkg}}.AllocZeroedAligned(alloc, unsafe.Sizeof({{.StructName}}{}), unsafe.Alignof({{.StructName}}{}))
 	if ptr == nil {
-		panic("cannot allocate memory for {{$.StructName}}")
+		return nil, {{.AllocatorPkg}}.ErrOutOfMemory
 	}
-	allocator.ZeroMem(ptr, unsafe.Sizeof({{.StructName}}{}))
 
 	v := (*{{.StructName}})(ptr)
 	v.__alloc = alloc
 	v.initNonPointerNonNativeFields()
-	return v
+	return v, nil
 }
 
 // InitAllocator sets the allocator used for fields
@@ -201,6 +240,9 @@ func (v *{{.StructName}}) Free() {
 					// {{$fld.Name}} is a pointer to an array/slice of pointers
 					// Free each non-nil element of the array (they are supposed to be unmanaged too)
 					{{- $c := counter}}
+					{{- if $fld.Opts.IsNative }}
+						if !{{$.AllocatorPkg}}.FreeIsNoop(v.__alloc) {
+					{{- end }}
 					vv{{$c}} := *v.{{$fld.Name}}
 					arrLen = len(vv{{$c}})
 					for idx := 0; idx < arrLen; idx += 1 {
@@ -212,17 +254,23 @@ func (v *{{.StructName}}) Free() {
 							{{- end }}
 						}
 					}
+					{{- if $fld.Opts.IsNative }}
+						}
+					{{- end }}
 				{{- else if $fld.Opts.IsNative }}
 					{{- if $fld.Opts.IsString }}
 						// {{$fld.Name}} is a pointer to an array/slice of strings
-						// Free each string data in the array (we don't own the string headers)
+						// Free each string data in the array (we don't own the string headers), unless the allocator
+						// releases memory in bulk
 						{{- $c := counter}}
-						vv{{$c}} := *v.{{$fld.Name}}
-						arrLen = len(vv{{$c}})
-						for idx := 0; idx < arrLen; idx += 1 {
-							bytePtr = unsafe.StringData(vv{{$c}}[idx])
-							if bytePtr != nil {
-								v.__alloc.Free(unsafe.Pointer(bytePtr))
+						if !{{$.AllocatorPkg}}.FreeIsNoop(v.__alloc) {
+							vv{{$c}} := *v.{{$fld.Name}}
+							arrLen = len(vv{{$c}})
+							for idx := 0; idx < arrLen; idx += 1 {
+								bytePtr = unsafe.StringData(vv{{$c}}[idx])
+								if bytePtr != nil {
+									v.__alloc.Free(unsafe.Pointer(bytePtr))
+								}
 							}
 						}
 					{{- /* else it is an array of things we don't need to free */ -}}
@@ -244,6 +292,9 @@ func (v *{{.StructName}}) Free() {
 		{{- if $fld.Opts.IsArraySliceOfPointers }}
 			// {{$fld.Name}} is an array/slice of pointers
 			// Free each non-nil element of the array (they are supposed to be unmanaged too)
+			{{- if $fld.Opts.IsNative }}
+				if !{{$.AllocatorPkg}}.FreeIsNoop(v.__alloc) {
+			{{- end }}
 			arrLen = len(v.{{$fld.Name}})
 			for idx := 0; idx < arrLen; idx += 1 {
 				if v.{{$fld.Name}}[idx] != nil {
@@ -254,16 +305,21 @@ func (v *{{.StructName}}) Free() {
 					{{- end }}
 				}
 			}
+			{{- if $fld.Opts.IsNative }}
+				}
+			{{- end }}
 		{{- else if $fld.Opts.IsNative }}
 			{{- if $fld.Opts.IsString }}
 				// {{$fld.Name}} is an array/slice of strings
-				// Free each string data (we don't own the string headers)
+				// Free each string data (we don't own the string headers), unless the allocator releases memory in bulk
 				{{- $c := counter}}
-				arrLen = len(v.{{$fld.Name}})
-				for idx := 0; idx < arrLen; idx += 1 {
-					bytePtr = unsafe.StringData(v.{{$fld.Name}}[idx])
-					if bytePtr != nil {
-						v.__alloc.Free(unsafe.Pointer(bytePtr))
+				if !{{$.AllocatorPkg}}.FreeIsNoop(v.__alloc) {
+					arrLen = len(v.{{$fld.Name}})
+					for idx := 0; idx < arrLen; idx += 1 {
+						bytePtr = unsafe.StringData(v.{{$fld.Name}}[idx])
+						if bytePtr != nil {
+							v.__alloc.Free(unsafe.Pointer(bytePtr))
+						}
 					}
 				}
 			{{- /* else it is an array of things we don't need to free */ -}}
@@ -330,13 +386,12 @@ func (v *{{.StructName}}) initNonPointerNonNativeFields() {
 {{- end }}
 }
 
-func (v *{{$.StructName}}) zeroAlloc(size uintptr) unsafe.Pointer {
-	ptr := v.__alloc.Alloc(size)
+func (v *{{$.StructName}}) zeroAlloc(size uintptr, alignment uintptr) (unsafe.Pointer, error) {
+	ptr := {{$.AllocatorPkg}}.AllocZeroedAligned(v.__alloc, size, alignment)
 	if ptr == nil {
-		panic("cannot allocate memory for {{$.StructName}}")
+		return nil, {{$.AllocatorPkg}}.ErrOutOfMemory
 	}
-	allocator.ZeroMem(ptr, size)
-	return ptr
+	return ptr, nil
 }
 `, funcMap, allocNF)
 	if err != nil {
@@ -351,6 +406,7 @@ func (sc *SaveContext) WriteStructFieldsSetters(st *Struct) error {
 	type SetterField struct {
 		FuncName              string
 		SetFuncPrefix         string
+		TrySetFuncPrefix      string
 		Name                  string
 		TypeName              string
 		TypeNamePrefixMod     string
@@ -446,13 +502,7 @@ func (sc *SaveContext) WriteStructFieldsSetters(st *Struct) error {
 			}
 
 			// Set method
-			if parser.IsPublic(name) {
-				setterField.FuncName = name
-				setterField.SetFuncPrefix = "Set"
-			} else {
-				setterField.FuncName = capitalizeFirstLetter(name)
-				setterField.SetFuncPrefix = "set"
-			}
+			setterField.FuncName, setterField.SetFuncPrefix, setterField.TrySetFuncPrefix = fieldFuncNames(name)
 
 			setter.SetterFields = append(setter.SetterFields, setterField)
 		}
@@ -479,24 +529,40 @@ func (sc *SaveContext) WriteStructFieldsSetters(st *Struct) error {
 	{{- if $fld.Opts.IsPointer }}
 		{{if not (isArrayOrSlice $fld.Opts.ArraySlice) }}
 			{{- /* a simple pointer */ -}}
-func (v *{{$.StructName}}) {{$fld.SetFuncPrefix}}{{$fld.FuncName}}(value *{{$fld.TypeName}}) {
 			{{- if $fld.Opts.IsNative }}
+func (v *{{$.StructName}}) {{$fld.SetFuncPrefix}}{{$fld.FuncName}}(value *{{$fld.TypeName}}) {
+				if err := v.{{$fld.TrySetFuncPrefix}}{{$fld.FuncName}}(value); err != nil {
+					panic("{{$.StructName}}::{{$fld.SetFuncPrefix}}{{$fld.FuncName}}: " + err.Error())
+				}
+}
+
+func (v *{{$.StructName}}) {{$fld.TrySetFuncPrefix}}{{$fld.FuncName}}(value *{{$fld.TypeName}}) error {
 				{{- if $fld.Opts.IsString }}
 					{{- /* a pointer to a string */ -}}
+					var newValue *string
+
+					if value != nil {
+						var err error
+
+						newValue, err = v.dupStringPtr(*value)
+						if err != nil {
+							return err
+						}
+					}
 					if v.{{$fld.Name}} != nil {
 						v.__alloc.Free(unsafe.Pointer(v.{{$fld.Name}}))
 					}
-					if value != nil {
-						v.{{$fld.Name}} = v.dupStringPtr(*value)
-					} else {
-						v.{{$fld.Name}} = nil
-					}
+					v.{{$fld.Name}} = newValue
 				{{- else }}
 					{{- /* a pointer to a native type */ -}}
 					if value != nil {
 						valueSize := unsafe.Sizeof(*value)
 						if v.{{$fld.Name}} == nil {
-							v.{{$fld.Name}} = (*{{$fld.TypeName}})(v.zeroAlloc(valueSize))
+							ptr, err := v.zeroAlloc(valueSize, unsafe.Alignof(*value))
+							if err != nil {
+								return err
+							}
+							v.{{$fld.Name}} = (*{{$fld.TypeName}})(ptr)
 						}
 						{{$.AllocatorPkg}}.CopyMem(unsafe.Pointer(v.{{$fld.Name}}), unsafe.Pointer(value), valueSize)
 					} else if v.{{$fld.Name}} != nil {
@@ -504,23 +570,94 @@ func (v *{{$.StructName}}) {{$fld.SetFuncPrefix}}{{$fld.FuncName}}(value *{{$fld
 						v.{{$fld.Name}} = nil
 					}
 				{{- end }}
+				return nil
+}
 			{{- else }}
-				{{- /* a pointer to a non-native object (it is supposed to be unmanaged too) */ -}}
+				{{- /* a pointer to a non-native object (it is supposed to be unmanaged too) */}}
+// {{$fld.SetFuncPrefix}}{{$fld.FuncName}} frees the current {{$fld.Name}} and stores value. The object
+// takes the ownership of value, so the caller must not free it
+func (v *{{$.StructName}}) {{$fld.SetFuncPrefix}}{{$fld.FuncName}}(value *{{$fld.TypeName}}) {
 				if v.{{$fld.Name}} != nil {
 					v.{{$fld.Name}}.Free()
 				}
 				v.{{$fld.Name}} = value
-			{{- end }}
 }
+			{{- end }}
 		{{- else }}
 			{{if isSlice $fld.Opts.ArraySlice }}
 				{{- /* a pointer to a slice of something */ -}}
 func (v *{{$.StructName}}) {{$fld.SetFuncPrefix}}{{$fld.FuncName}}Capacity(sliceLen int, preserve bool) {
+				if err := v.{{$fld.TrySetFuncPrefix}}{{$fld.FuncName}}Capacity(sliceLen, preserve); err != nil {
+					panic("{{$.StructName}}::{{$fld.SetFuncPrefix}}{{$fld.FuncName}}Capacity: " + err.Error())
+				}
+}
+
+func (v *{{$.StructName}}) {{$fld.TrySetFuncPrefix}}{{$fld.FuncName}}Capacity(sliceLen int, preserve bool) error {
 				var newSlice {{$fld.TypeNamePrefixMod}}{{$fld.TypeName}}
 
+				// Resize the current block if the allocator supports it
+				if preserve && sliceLen > 0 && v.{{$fld.Name}} != nil && unsafe.Alignof((*v.{{$fld.Name}})[0]) <= {{$.AllocatorPkg}}.DefaultAlignment {
+					if ra, ok := v.__alloc.({{$.AllocatorPkg}}.Reallocator); ok {
+						vv := *v.{{$fld.Name}}
+
+						dataSize, overflow := {{$.AllocatorPkg}}.MulUintptr(unsafe.Sizeof(vv[0]), uintptr(sliceLen))
+						if overflow {
+							return {{$.AllocatorPkg}}.ErrSizeOverflow
+						}
+						memSize, overflow := {{$.AllocatorPkg}}.AddUintptr(dataSize, unsafe.Sizeof(vv))
+						if overflow {
+							return {{$.AllocatorPkg}}.ErrSizeOverflow
+						}
+
+						{{- if or $fld.Opts.IsArraySliceOfPointers (or (not $fld.Opts.IsNative) $fld.Opts.IsString) }}
+							oldSliceLen := len(vv)
+
+							{{- if $fld.Opts.IsNative }}
+							// Free entries that do not fit, unless the allocator releases memory in bulk
+							if !{{$.AllocatorPkg}}.FreeIsNoop(v.__alloc) {
+							{{- else }}
+							// Free entries that do not fit. Nested objects may use their own allocator, so they are always freed
+							{{- end }}
+								for idx := sliceLen; idx < oldSliceLen; idx++ {
+									{{- if $fld.Opts.IsArraySliceOfPointers }}
+										v.{{$fld.SetFuncPrefix}}{{$fld.FuncName}}(idx, nil)
+									{{- else if $fld.Opts.IsNative }}
+										v.{{$fld.SetFuncPrefix}}{{$fld.FuncName}}(idx, unsafe.String(nil, 0))
+									{{- else }}
+										vv[idx].Free()
+									{{- end }}
+								}
+							{{- if $fld.Opts.IsNative }}
+							}
+							{{- end }}
+						{{- end }}
+
+						newSlice, err := v.reallocSlicePtr_{{$fld.FriendlyArrayTypeName}}(ra, v.{{$fld.Name}}, sliceLen, memSize)
+						if err != nil {
+							return err
+						}
+
+						{{- if and (not $fld.Opts.IsArraySliceOfPointers) (not $fld.Opts.IsNative) }}
+							// Initialize added non-native structs
+							for idx := oldSliceLen; idx < sliceLen; idx++ {
+								(*newSlice)[idx].InitAllocator(v.__alloc)
+							}
+						{{- end }}
+
+						// Replace
+						v.{{$fld.Name}} = newSlice
+						return nil
+					}
+				}
+
 				// Create the new slice
 				if sliceLen > 0 {
-					newSlice = v.allocSlicePtr_{{$fld.FriendlyArrayTypeName}}(sliceLen)
+					var err error
+
+					newSlice, err = v.allocSlicePtr_{{$fld.FriendlyArrayTypeName}}(sliceLen)
+					if err != nil {
+						return err
+					}
 				}
 
 				toPreserve := 0
@@ -542,20 +679,28 @@ func (v *{{$.StructName}}) {{$fld.SetFuncPrefix}}{{$fld.FuncName}}Capacity(slice
 					}
 
 					{{- if or $fld.Opts.IsArraySliceOfPointers (or (not $fld.Opts.IsNative) $fld.Opts.IsString) }}
-						// Free unused entries
-						for idx := toPreserve; idx < oldSliceLen; idx++ {
-							{{- if $fld.Opts.IsArraySliceOfPointers }}
-								v.{{$fld.SetFuncPrefix}}{{$fld.FuncName}}(idx, nil)
-							{{- else if $fld.Opts.IsNative }}
-								{{- if $fld.Opts.IsString }}
-									v.{{$fld.SetFuncPrefix}}{{$fld.FuncName}}(idx, unsafe.String(nil, 0))
-								{{- /* else it is pointer to a slice of things we don't need to free */ -}}
+						{{- if $fld.Opts.IsNative }}
+						// Free unused entries, unless the allocator releases memory in bulk
+						if !{{$.AllocatorPkg}}.FreeIsNoop(v.__alloc) {
+						{{- else }}
+						// Free unused entries. Nested objects may use their own allocator, so they are always freed
+						{{- end }}
+							for idx := toPreserve; idx < oldSliceLen; idx++ {
+								{{- if $fld.Opts.IsArraySliceOfPointers }}
+									v.{{$fld.SetFuncPrefix}}{{$fld.FuncName}}(idx, nil)
+								{{- else if $fld.Opts.IsNative }}
+									{{- if $fld.Opts.IsString }}
+										v.{{$fld.SetFuncPrefix}}{{$fld.FuncName}}(idx, unsafe.String(nil, 0))
+									{{- /* else it is pointer to a slice of things we don't need to free */ -}}
+									{{- end }}
+								{{- else }}
+									{{- /* a pointer to a slice of non-native objects (they are supposed to be unmanaged too) */ -}}
+									vv[idx].Free()
 								{{- end }}
-							{{- else }}
-								{{- /* a pointer to a slice of non-native objects (they are supposed to be unmanaged too) */ -}}
-								vv[idx].Free()
-							{{- end }}
+							}
+						{{- if $fld.Opts.IsNative }}
 						}
+						{{- end }}
 					{{- end }}
 
 					// Free old slice
@@ -571,12 +716,23 @@ func (v *{{$.StructName}}) {{$fld.SetFuncPrefix}}{{$fld.FuncName}}Capacity(slice
 
 				// Replace
 				v.{{$fld.Name}} = newSlice
+				return nil
 }
 			{{else }}
 				{{- /* a pointer to an array of something */ -}}
 func (v *{{$.StructName}}) {{$fld.SetFuncPrefix}}{{$fld.FuncName}}CreateArray() {
+				if err := v.{{$fld.TrySetFuncPrefix}}{{$fld.FuncName}}CreateArray(); err != nil {
+					panic("{{$.StructName}}::{{$fld.SetFuncPrefix}}{{$fld.FuncName}}CreateArray: " + err.Error())
+				}
+}
+
+func (v *{{$.StructName}}) {{$fld.TrySetFuncPrefix}}{{$fld.FuncName}}CreateArray() error {
+				newArray, err := v.allocArrayPtr_{{$fld.FriendlyArraySize}}{{$fld.FriendlyArrayTypeName}}()
+				if err != nil {
+					return err
+				}
 				v.{{$fld.SetFuncPrefix}}{{$fld.FuncName}}DestroyArray()
-				v.{{$fld.Name}} = v.allocArrayPtr_{{$fld.FriendlyArraySize}}{{$fld.FriendlyArrayTypeName}}()
+				v.{{$fld.Name}} = newArray
 
 				{{- if and (not $fld.Opts.IsArraySliceOfPointers) (not $fld.Opts.IsNative) }}
 					arrLen := len(v.{{$fld.Name}})
@@ -585,26 +741,35 @@ func (v *{{$.StructName}}) {{$fld.SetFuncPrefix}}{{$fld.FuncName}}CreateArray()
 						v.{{$fld.Name}}[idx].InitAllocator(v.__alloc)
 					}
 				{{- end }}
+				return nil
 }
 
 func (v *{{$.StructName}}) {{$fld.SetFuncPrefix}}{{$fld.FuncName}}DestroyArray() {
 				if v.{{$fld.Name}} != nil {
 					{{- if or $fld.Opts.IsArraySliceOfPointers (or (not $fld.Opts.IsNative) $fld.Opts.IsString) }}
-						// Free all entries
-						arrLen := len(v.{{$fld.Name}})
-						for idx := 0; idx < arrLen; idx++ {
-							{{- if $fld.Opts.IsArraySliceOfPointers }}
-								v.{{$fld.SetFuncPrefix}}{{$fld.FuncName}}(idx, nil)
-							{{- else if $fld.Opts.IsNative }}
-								{{- if $fld.Opts.IsString }}
-									v.{{$fld.SetFuncPrefix}}{{$fld.FuncName}}(idx, unsafe.String(nil, 0))
-								{{- /* else it is an array of things we don't need to free */ -}}
+						{{- if $fld.Opts.IsNative }}
+						// Free all entries, unless the allocator releases memory in bulk
+						if !{{$.AllocatorPkg}}.FreeIsNoop(v.__alloc) {
+						{{- else }}
+						// Free all entries. Nested objects may use their own allocator, so they are always freed
+						{{- end }}
+							arrLen := len(v.{{$fld.Name}})
+							for idx := 0; idx < arrLen; idx++ {
+								{{- if $fld.Opts.IsArraySliceOfPointers }}
+									v.{{$fld.SetFuncPrefix}}{{$fld.FuncName}}(idx, nil)
+								{{- else if $fld.Opts.IsNative }}
+									{{- if $fld.Opts.IsString }}
+										v.{{$fld.SetFuncPrefix}}{{$fld.FuncName}}(idx, unsafe.String(nil, 0))
+									{{- /* else it is an array of things we don't need to free */ -}}
+									{{- end }}
+								{{- else }}
+									{{- /* array/slice of non-native objects (they are supposed to be unmanaged too) */ -}}
+									v.{{$fld.Name}}[idx].Free()
 								{{- end }}
-							{{- else }}
-								{{- /* array/slice of non-native objects (they are supposed to be unmanaged too) */ -}}
-								v.{{$fld.Name}}[idx].Free()
-							{{- end }}
+							}
+						{{- if $fld.Opts.IsNative }}
 						}
+						{{- end }}
 					{{- end }}
 
 					// Free array
@@ -615,24 +780,40 @@ func (v *{{$.StructName}}) {{$fld.SetFuncPrefix}}{{$fld.FuncName}}DestroyArray()
 			{{- end }}
 			{{if $fld.Opts.IsArraySliceOfPointers }}
 				{{- /* a pointer to an array/slice of pointers */ -}}
-func (v *{{$.StructName}}) {{$fld.SetFuncPrefix}}{{$fld.FuncName}}(idx int, value *{{$fld.TypeName}}) {
-				// assert v.{{$fld.Name}} != nil && idx >= 0 && idx < len(*v.{{$fld.Name}})
-				vv := &((*v.{{$fld.Name}})[idx])
 				{{- if $fld.Opts.IsNative }}
+func (v *{{$.StructName}}) {{$fld.SetFuncPrefix}}{{$fld.FuncName}}(idx int, value *{{$fld.TypeName}}) {
+					if err := v.{{$fld.TrySetFuncPrefix}}{{$fld.FuncName}}(idx, value); err != nil {
+						panic("{{$.StructName}}::{{$fld.SetFuncPrefix}}{{$fld.FuncName}}: " + err.Error())
+					}
+}
+
+func (v *{{$.StructName}}) {{$fld.TrySetFuncPrefix}}{{$fld.FuncName}}(idx int, value *{{$fld.TypeName}}) error {
+					// assert v.{{$fld.Name}} != nil && idx >= 0 && idx < len(*v.{{$fld.Name}})
+					vv := &((*v.{{$fld.Name}})[idx])
 					{{- if $fld.Opts.IsString }}
+						var newValue *string
+
+						if value != nil {
+							var err error
+
+							newValue, err = v.dupStringPtr(*value)
+							if err != nil {
+								return err
+							}
+						}
 						if *vv != nil {
 							v.__alloc.Free(unsafe.Pointer(*vv))
 						}
-						if value != nil {
-							*vv = v.dupStringPtr(*value)
-						} else {
-							*vv = nil
-						}
+						*vv = newValue
 					{{- else }}
 						if value != nil {
 							valueSize := unsafe.Sizeof(*value)
 							if *vv == nil {
-								*vv = (*{{$fld.TypeName}})(v.zeroAlloc(valueSize))
+								ptr, err := v.zeroAlloc(valueSize, unsafe.Alignof(*value))
+								if err != nil {
+									return err
+								}
+								*vv = (*{{$fld.TypeName}})(ptr)
 							}
 							{{$.AllocatorPkg}}.CopyMem(unsafe.Pointer(*vv), unsafe.Pointer(value), valueSize)
 						} else if *vv != nil {
@@ -640,29 +821,49 @@ func (v *{{$.StructName}}) {{$fld.SetFuncPrefix}}{{$fld.FuncName}}(idx int, valu
 							*vv = nil
 						}
 					{{- end }}
+					return nil
+}
 				{{- else }}
+// {{$fld.SetFuncPrefix}}{{$fld.FuncName}} frees the element at position idx of {{$fld.Name}} and stores value. The object
+// takes the ownership of value, so the caller must not free it
+func (v *{{$.StructName}}) {{$fld.SetFuncPrefix}}{{$fld.FuncName}}(idx int, value *{{$fld.TypeName}}) {
+					// assert v.{{$fld.Name}} != nil && idx >= 0 && idx < len(*v.{{$fld.Name}})
+					vv := &((*v.{{$fld.Name}})[idx])
 					if *vv != nil {
 						(*vv).Free()
 					}
 					*vv = value
-				{{- end }}
 }
+				{{- end }}
 			{{else }}
 				{{- if $fld.Opts.IsNative }}
 					{{- if $fld.Opts.IsString }}
 						{{- /* a pointer to an array/slice of strings (we don't own the string headers) */ -}}
 func (v *{{$.StructName}}) {{$fld.SetFuncPrefix}}{{$fld.FuncName}}(idx int, value {{$fld.TypeName}}) {
+						if err := v.{{$fld.TrySetFuncPrefix}}{{$fld.FuncName}}(idx, value); err != nil {
+							panic("{{$.StructName}}::{{$fld.SetFuncPrefix}}{{$fld.FuncName}}: " + err.Error())
+						}
+}
+
+func (v *{{$.StructName}}) {{$fld.TrySetFuncPrefix}}{{$fld.FuncName}}(idx int, value {{$fld.TypeName}}) error {
 						// assert v.{{$fld.Name}} != nil && idx >= 0 && idx < len(*v.{{$fld.Name}})
 						vv := &((*v.{{$fld.Name}})[idx])
+						newValue, err := v.dupString(value)
+						if err != nil {
+							return err
+						}
 						bytePtr := unsafe.StringData(*vv)
 						if bytePtr != nil {
 							v.__alloc.Free(unsafe.Pointer(bytePtr))
 						}
-						*vv = v.dupString(value)
+						*vv = newValue
+						return nil
 }
 					{{- /* else it is an array of things we don't need to handle */ -}}
 					{{- end }}
 				{{- else }}
+// {{$fld.SetFuncPrefix}}{{$fld.FuncName}} frees the element at position idx of {{$fld.Name}} and stores value. The object
+// takes the ownership of value, so the caller must not free it
 func (v *{{$.StructName}}) {{$fld.SetFuncPrefix}}{{$fld.FuncName}}(idx int, value {{$fld.TypeName}}) {
 					{{- /* a pointer to an array/slice of non-native objects (they are supposed to be unmanaged too) */ -}}
 					// assert v.{{$fld.Name}} != nil && idx >= 0 && idx < len(*v.{{$fld.Name}})
@@ -677,11 +878,71 @@ func (v *{{$.StructName}}) {{$fld.SetFuncPrefix}}{{$fld.FuncName}}(idx int, valu
 		{{if isSlice $fld.Opts.ArraySlice }}
 			{{- /* a slice of something */ -}}
 func (v *{{$.StructName}}) {{$fld.SetFuncPrefix}}{{$fld.FuncName}}Capacity(sliceLen int, preserve bool) {
+			if err := v.{{$fld.TrySetFuncPrefix}}{{$fld.FuncName}}Capacity(sliceLen, preserve); err != nil {
+				panic("{{$.StructName}}::{{$fld.SetFuncPrefix}}{{$fld.FuncName}}Capacity: " + err.Error())
+			}
+}
+
+func (v *{{$.StructName}}) {{$fld.TrySetFuncPrefix}}{{$fld.FuncName}}Capacity(sliceLen int, preserve bool) error {
 			var newSlice {{$fld.TypeNamePrefixMod}}{{$fld.TypeName}}
 
+			// Resize the current block if the allocator supports it
+			if preserve && sliceLen > 0 && len(v.{{$fld.Name}}) > 0 && unsafe.Alignof(v.{{$fld.Name}}[0]) <= {{$.AllocatorPkg}}.DefaultAlignment {
+				if ra, ok := v.__alloc.({{$.AllocatorPkg}}.Reallocator); ok {
+					memSize, overflow := {{$.AllocatorPkg}}.MulUintptr(unsafe.Sizeof(v.{{$fld.Name}}[0]), uintptr(sliceLen))
+					if overflow {
+						return {{$.AllocatorPkg}}.ErrSizeOverflow
+					}
+
+					{{- if or $fld.Opts.IsArraySliceOfPointers (or (not $fld.Opts.IsNative) $fld.Opts.IsString) }}
+						oldSliceLen := len(v.{{$fld.Name}})
+
+						{{- if $fld.Opts.IsNative }}
+						// Free entries that do not fit, unless the allocator releases memory in bulk
+						if !{{$.AllocatorPkg}}.FreeIsNoop(v.__alloc) {
+						{{- else }}
+						// Free entries that do not fit. Nested objects may use their own allocator, so they are always freed
+						{{- end }}
+							for idx := sliceLen; idx < oldSliceLen; idx++ {
+								{{- if $fld.Opts.IsArraySliceOfPointers }}
+									v.{{$fld.SetFuncPrefix}}{{$fld.FuncName}}(idx, nil)
+								{{- else if $fld.Opts.IsNative }}
+									v.{{$fld.SetFuncPrefix}}{{$fld.FuncName}}(idx, unsafe.String(nil, 0))
+								{{- else }}
+									v.{{$fld.Name}}[idx].Free()
+								{{- end }}
+							}
+						{{- if $fld.Opts.IsNative }}
+						}
+						{{- end }}
+					{{- end }}
+
+					newSlice, err := v.reallocSlice_{{$fld.FriendlyArrayTypeName}}(ra, v.{{$fld.Name}}, sliceLen, memSize)
+					if err != nil {
+						return err
+					}
+
+					{{- if and (not $fld.Opts.IsArraySliceOfPointers) (not $fld.Opts.IsNative) }}
+						// Initialize added non-native structs
+						for idx := oldSliceLen; idx < sliceLen; idx++ {
+							newSlice[idx].InitAllocator(v.__alloc)
+						}
+					{{- end }}
+
+					// Replace
+					v.{{$fld.Name}} = newSlice
+					return nil
+				}
+			}
+
 			// Create the new slice
 			if sliceLen > 0 {
-				newSlice = v.allocSlice_{{$fld.FriendlyArrayTypeName}}(sliceLen)
+				var err error
+
+				newSlice, err = v.allocSlice_{{$fld.FriendlyArrayTypeName}}(sliceLen)
+				if err != nil {
+					return err
+				}
 			}
 
 			toPreserve := 0
@@ -702,20 +963,28 @@ func (v *{{$.StructName}}) {{$fld.SetFuncPrefix}}{{$fld.FuncName}}Capacity(slice
 				}
 
 				{{- if or $fld.Opts.IsArraySliceOfPointers (or (not $fld.Opts.IsNative) $fld.Opts.IsString) }}
-					// Free unused entries
-					for idx := toPreserve; idx < oldSliceLen; idx++ {
-						{{- if $fld.Opts.IsArraySliceOfPointers }}
-							v.{{$fld.SetFuncPrefix}}{{$fld.FuncName}}(idx, nil)
-						{{- else if $fld.Opts.IsNative }}
-							{{- if $fld.Opts.IsString }}
-								v.{{$fld.SetFuncPrefix}}{{$fld.FuncName}}(idx, unsafe.String(nil, 0))
-							{{- /* else it is pointer to a slice of things we don't need to free */ -}}
+					{{- if $fld.Opts.IsNative }}
+					// Free unused entries, unless the allocator releases memory in bulk
+					if !{{$.AllocatorPkg}}.FreeIsNoop(v.__alloc) {
+					{{- else }}
+					// Free unused entries. Nested objects may use their own allocator, so they are always freed
+					{{- end }}
+						for idx := toPreserve; idx < oldSliceLen; idx++ {
+							{{- if $fld.Opts.IsArraySliceOfPointers }}
+								v.{{$fld.SetFuncPrefix}}{{$fld.FuncName}}(idx, nil)
+							{{- else if $fld.Opts.IsNative }}
+								{{- if $fld.Opts.IsString }}
+									v.{{$fld.SetFuncPrefix}}{{$fld.FuncName}}(idx, unsafe.String(nil, 0))
+								{{- /* else it is pointer to a slice of things we don't need to free */ -}}
+								{{- end }}
+							{{- else }}
+								{{- /* a pointer to a slice of non-native objects (they are supposed to be unmanaged too) */ -}}
+								v.{{$fld.Name}}[idx].Free()
 							{{- end }}
-						{{- else }}
-							{{- /* a pointer to a slice of non-native objects (they are supposed to be unmanaged too) */ -}}
-							v.{{$fld.Name}}[idx].Free()
-						{{- end }}
+						}
+					{{- if $fld.Opts.IsNative }}
 					}
+					{{- end }}
 				{{- end }}
 
 				// Free old slice data
@@ -735,28 +1004,45 @@ func (v *{{$.StructName}}) {{$fld.SetFuncPrefix}}{{$fld.FuncName}}Capacity(slice
 
 			// Replace
 			v.{{$fld.Name}} = newSlice
+			return nil
 }
 		{{- end }}
 		{{if $fld.Opts.IsArraySliceOfPointers }}
 			{{- /* an array/slice of pointers */ -}}
-func (v *{{$.StructName}}) {{$fld.SetFuncPrefix}}{{$fld.FuncName}}(idx int, value *{{$fld.TypeName}}) {
-			// assert idx >= 0 && idx < len(v.{{$fld.Name}})
-			vv := &(v.{{$fld.Name}}[idx])
 			{{- if $fld.Opts.IsNative }}
+func (v *{{$.StructName}}) {{$fld.SetFuncPrefix}}{{$fld.FuncName}}(idx int, value *{{$fld.TypeName}}) {
+				if err := v.{{$fld.TrySetFuncPrefix}}{{$fld.FuncName}}(idx, value); err != nil {
+					panic("{{$.StructName}}::{{$fld.SetFuncPrefix}}{{$fld.FuncName}}: " + err.Error())
+				}
+}
+
+func (v *{{$.StructName}}) {{$fld.TrySetFuncPrefix}}{{$fld.FuncName}}(idx int, value *{{$fld.TypeName}}) error {
+				// assert idx >= 0 && idx < len(v.{{$fld.Name}})
+				vv := &(v.{{$fld.Name}}[idx])
 				{{- if $fld.Opts.IsString }}
+					var newValue *string
+
+					if value != nil {
+						var err error
+
+						newValue, err = v.dupStringPtr(*value)
+						if err != nil {
+							return err
+						}
+					}
 					if *vv != nil {
 						v.__alloc.Free(unsafe.Pointer(*vv))
 					}
-					if value != nil {
-						*vv = v.dupStringPtr(*value)
-					} else {
-						*vv = nil
-					}
+					*vv = newValue
 				{{- else }}
 					if value != nil {
 						valueSize := unsafe.Sizeof(*value)
 						if *vv == nil {
-							*vv = (*{{$fld.TypeName}})(v.zeroAlloc(valueSize))
+							ptr, err := v.zeroAlloc(valueSize, unsafe.Alignof(*value))
+							if err != nil {
+								return err
+							}
+							*vv = (*{{$fld.TypeName}})(ptr)
 						}
 						{{$.AllocatorPkg}}.CopyMem(unsafe.Pointer(*vv), unsafe.Pointer(value), valueSize)
 					} else if *vv != nil {
@@ -764,31 +1050,51 @@ func (v *{{$.StructName}}) {{$fld.SetFuncPrefix}}{{$fld.FuncName}}(idx int, valu
 						*vv = nil
 					}
 				{{- end }}
+				return nil
+}
 			{{- else }}
+// {{$fld.SetFuncPrefix}}{{$fld.FuncName}} frees the element at position idx of {{$fld.Name}} and stores value. The object
+// takes the ownership of value, so the caller must not free it
+func (v *{{$.StructName}}) {{$fld.SetFuncPrefix}}{{$fld.FuncName}}(idx int, value *{{$fld.TypeName}}) {
+				// assert idx >= 0 && idx < len(v.{{$fld.Name}})
+				vv := &(v.{{$fld.Name}}[idx])
 				if *vv != nil {
 					(*vv).Free()
 				}
 				*vv = value
-			{{- end }}
 }
+			{{- end }}
 		{{- else }}
 			{{- /* an array/slice of something */ -}}
 			{{- if $fld.Opts.IsNative }}
 				{{if $fld.Opts.IsString }}
 					{{- /* an array/slice of strings (we don't own the string header) */ -}}
 func (v *{{$.StructName}}) {{$fld.SetFuncPrefix}}{{$fld.FuncName}}(idx int, value {{$fld.TypeName}}) {
+					if err := v.{{$fld.TrySetFuncPrefix}}{{$fld.FuncName}}(idx, value); err != nil {
+						panic("{{$.StructName}}::{{$fld.SetFuncPrefix}}{{$fld.FuncName}}: " + err.Error())
+					}
+}
+
+func (v *{{$.StructName}}) {{$fld.TrySetFuncPrefix}}{{$fld.FuncName}}(idx int, value {{$fld.TypeName}}) error {
 					// assert idx >= 0 && idx < len(v.{{$fld.Name}})
 					vv := &(v.{{$fld.Name}}[idx])
+					newValue, err := v.dupString(value)
+					if err != nil {
+						return err
+					}
 					bytePtr := unsafe.StringData(*vv)
 					if bytePtr != nil {
 						v.__alloc.Free(unsafe.Pointer(bytePtr))
 					}
-					*vv = v.dupString(value)
+					*vv = newValue
+					return nil
 }
 				{{- /* else it is an array of things we don't need to handle */ -}}
 				{{- end }}
 			{{else }}
-				{{- /* an array/slice of non-native objects (they are supposed to be unmanaged too) */ -}}
+				{{- /* an array/slice of non-native objects (they are supposed to be unmanaged too) */}}
+// {{$fld.SetFuncPrefix}}{{$fld.FuncName}} frees the element at position idx of {{$fld.Name}} and stores value. The object
+// takes the ownership of value, so the caller must not free it
 func (v *{{$.StructName}}) {{$fld.SetFuncPrefix}}{{$fld.FuncName}}(idx int, value {{$fld.TypeName}}) {
 				// assert idx >= 0 && idx < len(v.{{$fld.Name}})
 				vv := &(v.{{$fld.Name}}[idx])
@@ -800,15 +1106,28 @@ func (v *{{$.StructName}}) {{$fld.SetFuncPrefix}}{{$fld.FuncName}}(idx int, valu
 	{{- else if $fld.Opts.IsNative }}
 		{{if $fld.Opts.IsString }}
 func (v *{{$.StructName}}) {{$fld.SetFuncPrefix}}{{$fld.FuncName}}(value {{$fld.TypeName}}) {
+			if err := v.{{$fld.TrySetFuncPrefix}}{{$fld.FuncName}}(value); err != nil {
+				panic("{{$.StructName}}::{{$fld.SetFuncPrefix}}{{$fld.FuncName}}: " + err.Error())
+			}
+}
+
+func (v *{{$.StructName}}) {{$fld.TrySetFuncPrefix}}{{$fld.FuncName}}(value {{$fld.TypeName}}) error {
+			newValue, err := v.dupString(value)
+			if err != nil {
+				return err
+			}
 			bytePtr := unsafe.StringData(v.{{$fld.Name}})
 			if bytePtr != nil {
 				v.__alloc.Free(unsafe.Pointer(bytePtr))
 			}
-			v.{{$fld.Name}} = v.dupString(value)
+			v.{{$fld.Name}} = newValue
+			return nil
 }
 		{{- end }}
 	{{else }}
-		{{- /* a non-native objects (it is supposed to be unmanaged too) */ -}}
+		{{- /* a non-native objects (it is supposed to be unmanaged too) */}}
+// {{$fld.SetFuncPrefix}}{{$fld.FuncName}} frees the current {{$fld.Name}} and stores value. The object
+// takes the ownership of value, so the caller must not free it
 func (v *{{$.StructName}}) {{$fld.SetFuncPrefix}}{{$fld.FuncName}}(value {{$fld.TypeName}}) {
 		v.{{$fld.Name}}.Free()
 		v.{{$fld.Name}} = value
@@ -817,114 +1136,186 @@ func (v *{{$.StructName}}) {{$fld.SetFuncPrefix}}{{$fld.FuncName}}(value {{$fld.
 {{- end }}
 
 {{range $key, $value := .NeedAllocSlice }}
-func (v *{{$.StructName}}) allocSlice_{{$key}}(sliceLen int) []{{$value}} {
+func (v *{{$.StructName}}) allocSlice_{{$key}}(sliceLen int) ([]{{$value}}, error) {
 	var tempT {{$value}}
 
 	memSize, overflow := {{$.AllocatorPkg}}.MulUintptr(unsafe.Sizeof(tempT), uintptr(sliceLen))
 	if overflow || sliceLen < 0 {
-		panic("{{$.StructName}}::allocSlice[{{$value}}]: size out of range")
+		return nil, {{$.AllocatorPkg}}.ErrSizeOverflow
 	}
 
-	data := v.zeroAlloc(memSize)
+	data, err := v.zeroAlloc(memSize, unsafe.Alignof(tempT))
+	if err != nil {
+		return nil, err
+	}
 	destSlice := unsafe.Slice((*{{$value}})(data), sliceLen)
-	return destSlice
+	return destSlice, nil
 }
 
-func (v *{{$.StructName}}) dupSlice_{{$key}}(src []{{$value}}) []{{$value}} {
+func (v *{{$.StructName}}) dupSlice_{{$key}}(src []{{$value}}) ([]{{$value}}, error) {
 	var tempT {{$value}}
 
 	arrLen := len(src)
-	destSlice := v.allocSlice_{{$key}}(arrLen)
+	destSlice, err := v.allocSlice_{{$key}}(arrLen)
+	if err != nil {
+		return nil, err
+	}
 	memSize, _ := {{$.AllocatorPkg}}.MulUintptr(unsafe.Sizeof(tempT), uintptr(arrLen))
 	{{$.AllocatorPkg}}.CopyMem(unsafe.Pointer(unsafe.SliceData(destSlice)), unsafe.Pointer(unsafe.SliceData(src)), memSize)
-	return destSlice
+	return destSlice, nil
+}
+
+// reallocSlice_{{$key}} resizes the block of a slice keeping its contents. New entries are zeroed. Shrinking
+// never fails, the original block is kept if it cannot be resized.
+func (v *{{$.StructName}}) reallocSlice_{{$key}}(ra {{$.AllocatorPkg}}.Reallocator, src []{{$value}}, sliceLen int, memSize uintptr) ([]{{$value}}, error) {
+	var tempT {{$value}}
+
+	oldSliceLen := len(src)
+	oldPtr := unsafe.Pointer(unsafe.SliceData(src))
+	ptr := ra.Realloc(oldPtr, memSize)
+	if ptr == nil {
+		if sliceLen > oldSliceLen {
+			return nil, {{$.AllocatorPkg}}.ErrOutOfMemory
+		}
+		ptr = oldPtr
+	} else if sliceLen > oldSliceLen {
+		oldSize := unsafe.Sizeof(tempT) * uintptr(oldSliceLen)
+		{{$.AllocatorPkg}}.ZeroMem(unsafe.Add(ptr, oldSize), memSize-oldSize)
+	}
+	return unsafe.Slice((*{{$value}})(ptr), sliceLen), nil
 }
 {{- end }}
 
 {{range $key, $value := .NeedAllocSlicePtr }}
-func (v *{{$.StructName}}) allocSlicePtr_{{$key}}(sliceLen int) *[]{{$value}} {
+func (v *{{$.StructName}}) allocSlicePtr_{{$key}}(sliceLen int) (*[]{{$value}}, error) {
 	var tempT {{$value}}
 	var memSize uintptr
 
 	dataSize, overflow := {{$.AllocatorPkg}}.MulUintptr(unsafe.Sizeof(tempT), uintptr(sliceLen))
 	if overflow || sliceLen < 0 {
-		panic("{{$.StructName}}::allocSlicePtr[{{$value}}]: size out of range")
+		return nil, {{$.AllocatorPkg}}.ErrSizeOverflow
 	}
 
 	hdrSize := unsafe.Sizeof([]{{$value}}{})
 	memSize, overflow = {{$.AllocatorPkg}}.AddUintptr(dataSize, hdrSize)
 	if overflow {
-		panic("{{$.StructName}}::allocSlicePtr[{{$value}}]: size out of range")
+		return nil, {{$.AllocatorPkg}}.ErrSizeOverflow
+	}
+	ptr, err := v.zeroAlloc(memSize, unsafe.Alignof(tempT))
+	if err != nil {
+		return nil, err
 	}
-	ptr := v.zeroAlloc(memSize)
 	data := unsafe.Add(ptr, hdrSize)
 	tmpSlice := unsafe.Slice((*{{$value}})(data), sliceLen)
 	{{$.AllocatorPkg}}.CopyMem(ptr, unsafe.Pointer(&tmpSlice), hdrSize)
-	return (*[]{{$value}})(ptr)
+	return (*[]{{$value}})(ptr), nil
 }
 
-func (v *{{$.StructName}}) dupSlicePtr_{{$key}}(src []{{$value}}) *[]{{$value}} {
+func (v *{{$.StructName}}) dupSlicePtr_{{$key}}(src []{{$value}}) (*[]{{$value}}, error) {
 	var tempT {{$value}}
 
 	sliceLen := len(src)
-	destSlice := v.allocSlicePtr_{{$key}}(sliceLen)
+	destSlice, err := v.allocSlicePtr_{{$key}}(sliceLen)
+	if err != nil {
+		return nil, err
+	}
 	memSize, _ := {{$.AllocatorPkg}}.MulUintptr(unsafe.Sizeof(tempT), uintptr(sliceLen))
 	{{$.AllocatorPkg}}.CopyMem(unsafe.Pointer(unsafe.SliceData(*destSlice)), unsafe.Pointer(unsafe.SliceData(src)), memSize)
-	return destSlice
+	return destSlice, nil
+}
+
+// reallocSlicePtr_{{$key}} resizes the block of a slice and its header keeping its contents. New entries are
+// zeroed. Shrinking never fails, the original block is kept if it cannot be resized.
+func (v *{{$.StructName}}) reallocSlicePtr_{{$key}}(ra {{$.AllocatorPkg}}.Reallocator, src *[]{{$value}}, sliceLen int, memSize uintptr) (*[]{{$value}}, error) {
+	var tempT {{$value}}
+
+	hdrSize := unsafe.Sizeof([]{{$value}}{})
+	oldSliceLen := len(*src)
+	ptr := ra.Realloc(unsafe.Pointer(src), memSize)
+	if ptr == nil {
+		if sliceLen > oldSliceLen {
+			return nil, {{$.AllocatorPkg}}.ErrOutOfMemory
+		}
+		ptr = unsafe.Pointer(src)
+	} else if sliceLen > oldSliceLen {
+		oldSize := hdrSize + unsafe.Sizeof(tempT)*uintptr(oldSliceLen)
+		{{$.AllocatorPkg}}.ZeroMem(unsafe.Add(ptr, oldSize), memSize-oldSize)
+	}
+
+	// The header must point to the (possibly moved) data
+	data := unsafe.Add(ptr, hdrSize)
+	tmpSlice := unsafe.Slice((*{{$value}})(data), sliceLen)
+	{{$.AllocatorPkg}}.CopyMem(ptr, unsafe.Pointer(&tmpSlice), hdrSize)
+	return (*[]{{$value}})(ptr), nil
 }
 {{- end }}
 
 {{- range $siz, $item := .NeedAllocArrayPtr }}
 {{range $key, $value := $item }}
-func (v *{{$.StructName}}) allocArrayPtr_{{$siz}}() *[{{$key}}]{{$value}} {
+func (v *{{$.StructName}}) allocArrayPtr_{{$siz}}() (*[{{$key}}]{{$value}}, error) {
 	var tempT {{$value}}
 
 	memSize, overflow := {{$.AllocatorPkg}}.MulUintptr(unsafe.Sizeof(tempT), uintptr({{$key}}))
 	if overflow {
-		panic("{{$.StructName}}::allocArrayPtr[[{{$key}}]{{$value}}]: size out of range")
+		return nil, {{$.AllocatorPkg}}.ErrSizeOverflow
+	}
+	ptr, err := v.zeroAlloc(memSize, unsafe.Alignof(tempT))
+	if err != nil {
+		return nil, err
 	}
-	ptr := v.zeroAlloc(memSize)
-	return (*[{{$key}}]{{$value}})(ptr)
+	return (*[{{$key}}]{{$value}})(ptr), nil
 }
 {{- end }}
 {{- end }}
 
 {{if .NeedAllocString }}
-func (v *{{$.StructName}}) allocString(strLen int) string {
+func (v *{{$.StructName}}) allocString(strLen int) (string, error) {
 	if strLen == 0 {
-		return unsafe.String(nil, 0)
+		return unsafe.String(nil, 0), nil
+	}
+	data, err := v.zeroAlloc(uintptr(strLen), 1)
+	if err != nil {
+		return "", err
 	}
-	data := v.zeroAlloc(uintptr(strLen))
 	destStr := unsafe.String((*byte)(data), strLen)
-	return destStr
+	return destStr, nil
 }
 
-func (v *{{$.StructName}}) dupString(s string) string {
+func (v *{{$.StructName}}) dupString(s string) (string, error) {
 	strLen := len(s)
-	destStr := v.allocString(strLen)
+	destStr, err := v.allocString(strLen)
+	if err != nil {
+		return "", err
+	}
 	{{$.AllocatorPkg}}.CopyMem(unsafe.Pointer(unsafe.StringData(destStr)), unsafe.Pointer(unsafe.StringData(s)), uintptr(strLen))
-	return destStr
+	return destStr, nil
 }
 {{- end }}
 
 {{if .NeedAllocStringPtr }}
-func (v *{{$.StructName}}) allocStringPtr(strLen int) *string {
+func (v *{{$.StructName}}) allocStringPtr(strLen int) (*string, error) {
 	hdrSize := unsafe.Sizeof("")
 	memSize, overflow := {{.AllocatorPkg}}.AddUintptr(uintptr(strLen), hdrSize)
-	if overflow {
-		panic("{{$.StructName}}::allocStringPtr: size out of range")
+	if overflow || strLen < 0 {
+		return nil, {{$.AllocatorPkg}}.ErrSizeOverflow
+	}
+	ptr, err := v.zeroAlloc(memSize, unsafe.Alignof(""))
+	if err != nil {
+		return nil, err
 	}
-	ptr := v.zeroAlloc(memSize)
 	data := unsafe.Add(ptr, hdrSize)
 	*((*string)(ptr)) = unsafe.String((*byte)(data), strLen)
-	return (*string)(ptr)
+	return (*string)(ptr), nil
 }
 
-func (v *{{$.StructName}}) dupStringPtr(s string) *string {
+func (v *{{$.StructName}}) dupStringPtr(s string) (*string, error) {
 	strLen := len(s)
-	destStr := v.allocStringPtr(strLen)
+	destStr, err := v.allocStringPtr(strLen)
+	if err != nil {
+		return nil, err
+	}
 	{{$.AllocatorPkg}}.CopyMem(unsafe.Pointer(unsafe.StringData(*destStr)), unsafe.Pointer(unsafe.StringData(s)), uintptr(strLen))
-	return destStr
+	return destStr, nil
 }
 {{- end }}
 `, funcMap, setter)
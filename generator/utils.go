@@ -2,6 +2,8 @@ package generator
 
 import (
 	"strings"
+
+	parser "github.com/mxmauro/gofile-parser"
 )
 
 // -----------------------------------------------------------------------------
@@ -10,3 +12,31 @@ func capitalizeFirstLetter(s string) string {
 	runes := []rune(s)
 	return strings.ToUpper(string(runes[0])) + string(runes[1:])
 }
+
+func lowerFirstLetter(s string) string {
+	runes := []rune(s)
+	return strings.ToLower(string(runes[0])) + string(runes[1:])
+}
+
+// fieldFuncNames returns the name used to build the helper methods of a field and the prefixes of its setters
+func fieldFuncNames(name string) (funcName string, setFuncPrefix string, trySetFuncPrefix string) {
+	if parser.IsPublic(name) {
+		return name, "Set", "TrySet"
+	}
+	return capitalizeFirstLetter(name), "set", "trySet"
+}
+
+// funcNameForType returns the name of a function generated for the given (possibly qualified) unmanaged type, like
+// NewUnmanagedSample or tryNewUnmanagedSample.
+func funcNameForType(typeName string, prefix string, suffix string) string {
+	pkgName, name := parser.GetIdentifierParts(typeName)
+	if parser.IsPublic(name) {
+		name = capitalizeFirstLetter(prefix) + name + suffix
+	} else {
+		name = prefix + capitalizeFirstLetter(name) + suffix
+	}
+	if len(pkgName) > 0 {
+		name = pkgName + "." + name
+	}
+	return name
+}
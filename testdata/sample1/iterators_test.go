@@ -0,0 +1,64 @@
+//go:build go1.23
+
+// Ranging over the iterators requires Go 1.23, while the module declares an older version, so this file upgrades
+// its own language version with the build constraint.
+
+package sample1
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/mxmauro/unmanagedgen/allocator/c"
+)
+
+// -----------------------------------------------------------------------------
+
+func TestSample1Iterators(t *testing.T) {
+	alloc := c.NewWithDebug()
+
+	v := NewUnmanagedSample(alloc)
+	for range v.AllPtrToSliceOfStrings() {
+		t.Fatalf("nil slice has elements")
+	}
+
+	count := SamplesCount/100 + 1
+	for idx := 0; idx < count; idx++ {
+		s := strconv.Itoa(idx)
+		v.AppendSliceOfStrings(s)
+		v.AppendPtrToSliceOfStrings(s)
+		v.SetSliceOfSubsamplesCapacity(idx+1, true)
+		v.SliceOfSubsamples[idx].SetSomeString(s)
+	}
+
+	visited := 0
+	for idx, s := range v.AllSliceOfStrings() {
+		if idx != visited || s != strconv.Itoa(idx) || (*v.PtrToSliceOfStrings)[idx] != s {
+			t.Fatalf("unexpected element")
+		}
+		visited++
+	}
+	if visited != count {
+		t.Fatalf("not all elements visited")
+	}
+
+	// Nested objects are handed out by pointer and the iteration stops on break
+	visited = 0
+	for idx, ss := range v.BackwardSliceOfSubsamples() {
+		if idx != count-1-visited || ss != &v.SliceOfSubsamples[idx] {
+			t.Fatalf("unexpected element")
+		}
+		visited++
+		if visited == 10 {
+			break
+		}
+	}
+	if visited != 10 {
+		t.Fatalf("iteration not stopped")
+	}
+
+	v.Free()
+	if alloc.Usage() != 0 {
+		t.Fatalf("Usage is not zero! [%v]", alloc.Usage())
+	}
+}
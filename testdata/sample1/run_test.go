@@ -1,17 +1,33 @@
 package sample1
 
 import (
+	"errors"
+	"math"
 	"math/rand"
+	"reflect"
+	"strconv"
 	"strings"
 	"testing"
+	"unsafe"
 
+	"github.com/mxmauro/unmanagedgen/allocator"
+	"github.com/mxmauro/unmanagedgen/allocator/arena"
+	"github.com/mxmauro/unmanagedgen/allocator/budget"
 	"github.com/mxmauro/unmanagedgen/allocator/c"
+	"github.com/mxmauro/unmanagedgen/allocator/faultinject"
 )
 
 // -----------------------------------------------------------------------------
 
 const SamplesCount = 1000000
 
+// -----------------------------------------------------------------------------
+
+type freeCountingArena struct {
+	*arena.ArenaAllocator
+	frees int
+}
+
 /*
 
 type Allocator struct {
@@ -36,10 +52,47 @@ func (a *Allocator) Free(ptr unsafe.Pointer) {
 // -----------------------------------------------------------------------------
 
 func TestSample1(t *testing.T) {
-	alloc := c.New()
+	alloc := c.NewWithStats()
+
+	runSampleChanges(t, alloc, SamplesCount)
+
+	if alloc.Stats().BytesInUse != 0 {
+		t.Fatalf("Usage is not zero! [%v]", alloc.Stats().BytesInUse)
+	}
+}
+
+func TestSample1Debug(t *testing.T) {
+	// Tracking every allocation is slow, so this run uses fewer samples. Double frees and foreign pointers make Free
+	// panic, and the quarantine catches writes to freed blocks
+	alloc := c.NewWithDebugOptions(c.DebugOptions{
+		TrackAllocations: true,
+		QuarantineSize:   1024,
+	})
+
+	runSampleChanges(t, alloc, SamplesCount/100+1)
+
+	if err := alloc.CheckAll(); err != nil {
+		t.Fatal(err)
+	}
+	if leaks := alloc.Leaks(); len(leaks) != 0 {
+		t.Fatalf("%v leaks found, the largest one:\n%v", len(leaks), leaks[0])
+	}
+	if alloc.Usage() != 0 {
+		t.Fatalf("Usage is not zero! [%v]", alloc.Usage())
+	}
+}
+
+func runSampleChanges(t *testing.T, alloc allocator.Allocator, count int) {
+	arr := newChangedSamples(t, alloc, count)
 
-	t.Logf("Initializing %v elements", SamplesCount)
-	arr := make([]*UnmanagedSample, SamplesCount)
+	t.Log("Freeing elements")
+	freeSamples(arr)
+}
+
+// newChangedSamples creates count samples with alloc and makes, on average, 200 random changes to each of them
+func newChangedSamples(t *testing.T, alloc allocator.Allocator, count int) []*UnmanagedSample {
+	t.Logf("Initializing %v elements", count)
+	arr := make([]*UnmanagedSample, count)
 	for idx := 0; idx < len(arr); idx++ {
 		arr[idx] = NewUnmanagedSample(alloc)
 	}
@@ -57,17 +110,542 @@ func TestSample1(t *testing.T) {
 			}
 		}
 	}
+	return arr
+}
 
-	t.Log("Freeing elements")
+func freeSamples(arr []*UnmanagedSample) {
 	for idx := 0; idx < len(arr); idx++ {
 		arr[idx].Free()
 	}
+}
 
-	/*
-		if alloc.Usage() != 0 {
-			t.Fatalf("Usage is not zero! [%v]", alloc.Usage())
+func TestSample1TryFunctions(t *testing.T) {
+	debugAlloc := c.NewWithDebug()
+	alloc := budget.New(debugAlloc, budget.Options{
+		Limit: 64 * 1024,
+	})
+
+	_, err := TryNewUnmanagedSample(budget.New(debugAlloc, budget.Options{
+		Limit: 16,
+	}))
+	if !errors.Is(err, allocator.ErrOutOfMemory) {
+		t.Fatalf("expected ErrOutOfMemory [err=%v]", err)
+	}
+
+	v, err := TryNewUnmanagedSample(alloc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = v.TrySetSomeString("hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = v.TrySetSomeString(strings.Repeat("*", 128*1024))
+	if !errors.Is(err, allocator.ErrOutOfMemory) || v.SomeString != "hello" {
+		t.Fatalf("object modified after a failed allocation [err=%v]", err)
+	}
+
+	err = v.TrySetSliceOfIntsCapacity(4, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v.SliceOfInts[3] = 10
+	err = v.TrySetSliceOfIntsCapacity(math.MaxInt, true)
+	if !errors.Is(err, allocator.ErrSizeOverflow) || len(v.SliceOfInts) != 4 || v.SliceOfInts[3] != 10 {
+		t.Fatalf("object modified after a failed allocation [err=%v]", err)
+	}
+
+	err = v.TrySetPtrToArrayOfStringsCreateArray()
+	if err != nil {
+		t.Fatal(err)
+	}
+	v.SetPtrToArrayOfStrings(1, "world")
+	err = v.TrySetPtrToArrayOfStrings(2, strings.Repeat("*", 128*1024))
+	if !errors.Is(err, allocator.ErrOutOfMemory) || (*v.PtrToArrayOfStrings)[1] != "world" {
+		t.Fatalf("object modified after a failed allocation [err=%v]", err)
+	}
+
+	v.Free()
+	if debugAlloc.Usage() != 0 {
+		t.Fatalf("Usage is not zero! [%v]", debugAlloc.Usage())
+	}
+}
+
+func TestSample1FaultInjection(t *testing.T) {
+	debugAlloc := c.NewWithDebug()
+
+	points := faultinject.InjectEach(debugAlloc, func(alloc allocator.Allocator, inj *faultinject.Injector) {
+		v, err := TryNewUnmanagedSample(alloc)
+		if err == nil {
+			steps := []func() error{
+				func() error { return v.TrySetSomeString("hello") },
+				func() error { return v.TrySetSliceOfStringsCapacity(4, false) },
+				func() error { return v.TrySetSliceOfStrings(1, "world") },
+				func() error { return v.TrySetSliceOfStringsCapacity(8, true) },
+				func() error { return v.TrySetPtrToSliceOfSubsamplesCapacity(2, false) },
+				func() error { return (*v.PtrToSliceOfSubsamples)[1].TrySetSomeString("sub") },
+				func() error { return v.TrySetPtrToString(getRandomPtrToString()) },
+			}
+			for _, step := range steps {
+				err = step()
+				if err != nil {
+					break
+				}
+			}
+			v.Free()
+		}
+		if err != nil && !errors.Is(err, allocator.ErrOutOfMemory) {
+			t.Fatalf("unexpected error [err=%v]", err)
 		}
-	*/
+		if debugAlloc.Usage() != 0 {
+			t.Fatalf("Usage is not zero after failing allocation #%v! [%v]", inj.Allocations(), debugAlloc.Usage())
+		}
+	})
+	if points == 0 {
+		t.Fatalf("no allocation points exercised")
+	}
+}
+
+func TestSample1ExtendedAllocator(t *testing.T) {
+	// CAllocator implements the calloc and realloc extensions
+	alloc := c.NewWithStats()
+
+	v := NewUnmanagedSample(alloc)
+	v.SetSliceOfIntsCapacity(2, false)
+	v.SliceOfInts[1] = 10
+	v.SetSliceOfIntsCapacity(1024, true)
+	if v.SliceOfInts[1] != 10 || v.SliceOfInts[1023] != 0 {
+		t.Fatalf("slice contents not preserved after growing")
+	}
+	v.SetPtrToSliceOfStringsCapacity(2, false)
+	v.SetPtrToSliceOfStrings(1, "hello")
+	v.SetPtrToSliceOfStringsCapacity(1024, true)
+	if (*v.PtrToSliceOfStrings)[1] != "hello" || len(*v.PtrToSliceOfStrings) != 1024 {
+		t.Fatalf("slice contents not preserved after growing")
+	}
+	v.SetPtrToSliceOfStringsCapacity(1, true)
+	if len(*v.PtrToSliceOfStrings) != 1 || (*v.PtrToSliceOfStrings)[0] != "" {
+		t.Fatalf("slice not shrunk")
+	}
+	v.Free()
+
+	if alloc.Stats().BytesInUse != 0 {
+		t.Fatalf("Usage is not zero! [%v]", alloc.Stats().BytesInUse)
+	}
+}
+
+func TestSample1BulkRelease(t *testing.T) {
+	backing := c.NewWithDebug()
+	alloc := &freeCountingArena{
+		ArenaAllocator: arena.New(backing, 0),
+	}
+
+	arr := newChangedSamples(t, alloc, SamplesCount/100+1)
+	for idx := 0; idx < len(arr); idx++ {
+		arr[idx].SetSliceOfStringsCapacity(4, false)
+		arr[idx].SetSliceOfStrings(0, "hello")
+		arr[idx].SetPtrToArrayOfSubsamplesCreateArray()
+	}
+	frees := alloc.frees
+
+	// Removed elements are not freed either
+	for idx := 0; idx < len(arr); idx++ {
+		arr[idx].RemoveSliceOfStrings(0)
+	}
+	if alloc.frees != frees {
+		t.Fatalf("unexpected frees [%v]", alloc.frees-frees)
+	}
+
+	// Only the slice data should be freed, the string data is released in bulk by the allocator
+	for idx := 0; idx < len(arr); idx++ {
+		arr[idx].SetSliceOfStringsCapacity(0, false)
+	}
+	if alloc.frees != frees+len(arr) {
+		t.Fatalf("unexpected frees [%v]", alloc.frees-frees)
+	}
+
+	for idx := 0; idx < len(arr); idx++ {
+		arr[idx].SetPtrToArrayOfSubsamplesDestroyArray()
+		arr[idx].Free()
+	}
+
+	alloc.Release()
+	if backing.Usage() != 0 {
+		t.Fatalf("Usage is not zero! [%v]", backing.Usage())
+	}
+}
+
+func TestSample1BulkReleaseNestedAllocator(t *testing.T) {
+	backing := c.NewWithDebug()
+	alloc := arena.New(backing, 0)
+	childAlloc := c.NewWithDebug()
+
+	v := NewUnmanagedSample(alloc)
+	v.SetSomeString("parent")
+
+	// Nested objects created with their own allocator must be freed even if the parent's allocator releases in bulk
+	ss := NewUnmanagedSubSample(childAlloc)
+	ss.SetSomeString("child")
+	v.SetPtrToSomeSubsample(ss)
+
+	v.SetSliceOfPtrToSubsamplesCapacity(2, false)
+	ss = NewUnmanagedSubSample(childAlloc)
+	ss.SetSomeString("slice child")
+	v.SetSliceOfPtrToSubsamples(1, ss)
+
+	v.SetArrayOfPtrToSubsamples(0, NewUnmanagedSubSample(childAlloc))
+
+	v.Free()
+	alloc.Release()
+
+	if childAlloc.Usage() != 0 {
+		t.Fatalf("Child usage is not zero! [%v]", childAlloc.Usage())
+	}
+	if backing.Usage() != 0 {
+		t.Fatalf("Usage is not zero! [%v]", backing.Usage())
+	}
+}
+
+func TestSample1Conversion(t *testing.T) {
+	alloc := c.NewWithDebug()
+
+	src := &Sample{
+		SomeString:          "hello",
+		SliceOfStrings:      []string{"a", "", "b"},
+		ArrayOfPtrToStrings: [4]*string{nil, getRandomPtrToString()},
+		PtrToSliceOfPtrToSubsamples: &[]*SubSample{
+			{SomeInt: 1, SomeString: "sub"},
+			nil,
+		},
+	}
+	v := NewUnmanagedSampleFrom(alloc, src)
+	if !reflect.DeepEqual(v.ToManaged(), src) {
+		t.Fatalf("managed copy differs from the source")
+	}
+	v.Free()
+
+	// Pointers to empty slices survive the round trip, empty slices come back as nil
+	src = &Sample{
+		SliceOfInts:                 []int{},
+		PtrToSliceOfInts:            &[]int{},
+		PtrToSliceOfStrings:         &[]string{},
+		PtrToSliceOfPtrToSubsamples: &[]*SubSample{},
+	}
+	v = NewUnmanagedSampleFrom(alloc, src)
+	managed := v.ToManaged()
+	if managed.SliceOfInts != nil ||
+		managed.PtrToSliceOfInts == nil || *managed.PtrToSliceOfInts == nil || len(*managed.PtrToSliceOfInts) != 0 ||
+		managed.PtrToSliceOfStrings == nil || len(*managed.PtrToSliceOfStrings) != 0 ||
+		managed.PtrToSliceOfPtrToSubsamples == nil || len(*managed.PtrToSliceOfPtrToSubsamples) != 0 {
+		t.Fatalf("empty slices not preserved")
+	}
+	src.SliceOfInts = nil
+	if !reflect.DeepEqual(managed, src) {
+		t.Fatalf("managed copy differs from the source")
+	}
+	v.Free()
+
+	arr := newChangedSamples(t, alloc, SamplesCount/100+1)
+	for idx := 0; idx < len(arr); idx++ {
+		managed := arr[idx].ToManaged()
+		arr[idx].Free()
+
+		v = NewUnmanagedSampleFrom(alloc, managed)
+		if !reflect.DeepEqual(v.ToManaged(), managed) {
+			t.Fatalf("round trip of element #%v failed", idx)
+		}
+		v.Free()
+	}
+
+	if alloc.Usage() != 0 {
+		t.Fatalf("Usage is not zero! [%v]", alloc.Usage())
+	}
+}
+
+func TestSample1Clone(t *testing.T) {
+	srcAlloc := c.NewWithDebug()
+	dstAlloc := c.NewWithDebug()
+
+	arr := newChangedSamples(t, srcAlloc, SamplesCount/100+1)
+	managed := make([]*Sample, len(arr))
+	clones := make([]*UnmanagedSample, len(arr))
+	for idx := 0; idx < len(arr); idx++ {
+		managed[idx] = arr[idx].ToManaged()
+		clones[idx] = arr[idx].Clone(dstAlloc)
+	}
+
+	// Clone into an embedded value that already has contents
+	var embedded UnmanagedSample
+	embedded.InitAllocator(dstAlloc)
+	arr[0].CloneInto(&embedded)
+	arr[1].CloneInto(&embedded)
+
+	for idx := 0; idx < len(arr); idx++ {
+		arr[idx].Free()
+	}
+	if srcAlloc.Usage() != 0 {
+		t.Fatalf("Usage is not zero! [%v]", srcAlloc.Usage())
+	}
+
+	if !reflect.DeepEqual(embedded.ToManaged(), managed[1]) {
+		t.Fatalf("embedded clone differs from the source")
+	}
+	embedded.Free()
+	for idx := 0; idx < len(arr); idx++ {
+		if !reflect.DeepEqual(clones[idx].ToManaged(), managed[idx]) {
+			t.Fatalf("clone of element #%v differs from the source", idx)
+		}
+		clones[idx].Free()
+	}
+	if dstAlloc.Usage() != 0 {
+		t.Fatalf("Usage is not zero! [%v]", dstAlloc.Usage())
+	}
+}
+
+func TestSample1CloneEmptyPointerSlices(t *testing.T) {
+	alloc := c.NewWithDebug()
+
+	// Pointers to empty slices are kept by the clone, whether they come from Reserve or from a managed struct
+	v := NewUnmanagedSampleFrom(alloc, &Sample{
+		PtrToSliceOfStrings:    &[]string{},
+		PtrToSliceOfSubsamples: &[]SubSample{},
+	})
+	v.ReservePtrToSliceOfInts(0)
+	clone := v.Clone(alloc)
+	if clone.PtrToSliceOfInts == nil || clone.PtrToSliceOfStrings == nil || clone.PtrToSliceOfSubsamples == nil ||
+		clone.PtrToSliceOfPtrToInts != nil || !clone.Equal(v) || clone.Hash(1) != v.Hash(1) {
+		t.Fatalf("clone differs from the source")
+	}
+
+	// Also when the destination had contents
+	clone.SetPtrToSliceOfIntsCapacity(4, true)
+	v.CloneInto(clone)
+	if clone.PtrToSliceOfInts == nil || len(*clone.PtrToSliceOfInts) != 0 || !clone.Equal(v) {
+		t.Fatalf("clone differs from the source")
+	}
+
+	clone.Free()
+	v.Free()
+	if alloc.Usage() != 0 {
+		t.Fatalf("Usage is not zero! [%v]", alloc.Usage())
+	}
+}
+
+func TestSample1EqualAndHash(t *testing.T) {
+	alloc := c.NewWithDebug()
+
+	arr := newChangedSamples(t, alloc, SamplesCount/100+1)
+	for idx := 0; idx < len(arr); idx++ {
+		clone := arr[idx].Clone(alloc)
+		if !clone.Equal(arr[idx]) || clone.Hash(1) != arr[idx].Hash(1) {
+			t.Fatalf("clone of element #%v is not equal to the source", idx)
+		}
+		if clone.Hash(1) == clone.Hash(2) {
+			t.Fatalf("seed of element #%v not used", idx)
+		}
+
+		clone.SetSomeString(arr[idx].SomeString + "*")
+		if clone.Equal(arr[idx]) || clone.Hash(1) == arr[idx].Hash(1) {
+			t.Fatalf("modified clone of element #%v is equal to the source", idx)
+		}
+		clone.Free()
+	}
+	freeSamples(arr)
+
+	// A nil pointer to a slice differs from a pointer to an empty one, while nil and empty slices are the same
+	for _, reserve := range []func(v *UnmanagedSample){
+		func(v *UnmanagedSample) { v.ReservePtrToSliceOfInts(0) },
+		func(v *UnmanagedSample) { v.ReservePtrToSliceOfStrings(0) },
+		func(v *UnmanagedSample) { v.ReservePtrToSliceOfSubsamples(0) },
+		func(v *UnmanagedSample) { v.ReservePtrToSliceOfPtrToSubsamples(0) },
+	} {
+		withNil := NewUnmanagedSample(alloc)
+		withEmpty := NewUnmanagedSample(alloc)
+		withEmpty.ReserveSliceOfStrings(4)
+		if !withNil.Equal(withEmpty) || withNil.Hash(1) != withEmpty.Hash(1) {
+			t.Fatalf("nil slice is not equal to an empty one")
+		}
+
+		reserve(withEmpty)
+		if withNil.Equal(withEmpty) || withEmpty.Equal(withNil) || withNil.Hash(1) == withEmpty.Hash(1) {
+			t.Fatalf("nil pointer to a slice is equal to a pointer to an empty one")
+		}
+		reserve(withNil)
+		if !withNil.Equal(withEmpty) || withNil.Hash(1) != withEmpty.Hash(1) {
+			t.Fatalf("pointers to empty slices are not equal")
+		}
+
+		withNil.Free()
+		withEmpty.Free()
+	}
+
+	if alloc.Usage() != 0 {
+		t.Fatalf("Usage is not zero! [%v]", alloc.Usage())
+	}
+}
+
+func TestSample1SliceHelpers(t *testing.T) {
+	debugAlloc := c.NewWithDebug()
+	statsAlloc := c.NewWithStats()
+
+	// The allocator with stats supports realloc
+	for _, alloc := range []allocator.Allocator{debugAlloc, statsAlloc} {
+		v := NewUnmanagedSample(alloc)
+
+		var strs, ptrStrs []string
+		var ints []int
+		for idx := 0; idx < SamplesCount/100+1; idx++ {
+			s := strings.Repeat("*", rand.Intn(64))
+			switch rand.Intn(5) {
+			case 0, 1:
+				pos := rand.Intn(len(strs) + 1)
+				v.InsertSliceOfStrings(pos, s)
+				strs = append(strs[:pos], append([]string{s}, strs[pos:]...)...)
+
+				v.AppendPtrToSliceOfStrings(s)
+				ptrStrs = append(ptrStrs, s)
+
+				v.AppendSliceOfInts(idx)
+				ints = append(ints, idx)
+
+			case 2:
+				if len(strs) > 0 {
+					pos := rand.Intn(len(strs))
+					v.RemoveSliceOfStrings(pos)
+					strs = append(strs[:pos], strs[pos+1:]...)
+				}
+				if len(ptrStrs) > 0 {
+					v.RemovePtrToSliceOfStrings(0)
+					ptrStrs = ptrStrs[1:]
+				}
+
+			case 3:
+				newLen := len(strs) / 2
+				v.TruncateSliceOfStrings(newLen)
+				strs = strs[:newLen]
+
+			case 4:
+				v.ReserveSliceOfStrings(len(strs) + rand.Intn(16))
+				v.ReservePtrToSliceOfStrings(len(ptrStrs) + rand.Intn(16))
+			}
+
+			ss := NewUnmanagedSubSample(alloc)
+			ss.SetSomeString(s)
+			v.AppendSliceOfPtrToSubsamples(ss)
+			// Appended values are owned by the slice, like the pointers above
+			var sub UnmanagedSubSample
+			sub.InitAllocator(alloc)
+			if rand.Intn(2) == 0 {
+				sub.SetSomeString(s)
+				v.AppendPtrToSliceOfSubsamples(sub)
+			} else {
+				v.AppendPtrToSliceOfSubsamples(sub)
+				(*v.PtrToSliceOfSubsamples)[len(*v.PtrToSliceOfSubsamples)-1].SetSomeString(s)
+			}
+			if (*v.PtrToSliceOfSubsamples)[len(*v.PtrToSliceOfSubsamples)-1].SomeString != s {
+				t.Fatalf("appended element has an unexpected value")
+			}
+			if rand.Intn(2) == 0 {
+				v.RemoveSliceOfPtrToSubsamples(rand.Intn(len(v.SliceOfPtrToSubsamples)))
+				v.TruncatePtrToSliceOfSubsamples(len(*v.PtrToSliceOfSubsamples) - 1)
+			}
+		}
+
+		if !reflect.DeepEqual(append([]string{}, v.SliceOfStrings...), append([]string{}, strs...)) ||
+			!reflect.DeepEqual(append([]string{}, *v.PtrToSliceOfStrings...), append([]string{}, ptrStrs...)) ||
+			!reflect.DeepEqual(append([]int{}, v.SliceOfInts...), append([]int{}, ints...)) {
+			t.Fatalf("slice contents differ from the expected ones")
+		}
+		if cap(v.SliceOfStrings) < len(v.SliceOfStrings) || len(v.SliceOfPtrToSubsamples) != len(*v.PtrToSliceOfSubsamples) {
+			t.Fatalf("inconsistent slice lengths")
+		}
+
+		v.Free()
+	}
+
+	// Invalid positions are rejected before the slices grow
+	v := NewUnmanagedSample(debugAlloc)
+	v.SetSliceOfStringsCapacity(2, false)
+	usage := debugAlloc.Usage()
+	if err := v.TryInsertSliceOfStrings(3, "x"); !errors.Is(err, allocator.ErrIndexOutOfRange) {
+		t.Fatalf("invalid position accepted [err=%v]", err)
+	}
+	if err := v.TryInsertPtrToSliceOfStrings(1, "x"); !errors.Is(err, allocator.ErrIndexOutOfRange) {
+		t.Fatalf("invalid position accepted [err=%v]", err)
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatalf("invalid position accepted")
+			}
+		}()
+		v.InsertSliceOfStrings(-1, "x")
+	}()
+	if cap(v.SliceOfStrings) != 2 || v.PtrToSliceOfStrings != nil || debugAlloc.Usage() != usage {
+		t.Fatalf("slices modified by an invalid insertion")
+	}
+	v.Free()
+
+	if debugAlloc.Usage() != 0 {
+		t.Fatalf("Usage is not zero! [%v]", debugAlloc.Usage())
+	}
+	if statsAlloc.Stats().BytesInUse != 0 {
+		t.Fatalf("Usage is not zero! [%v]", statsAlloc.Stats().BytesInUse)
+	}
+}
+
+func TestSample1SortAndSearch(t *testing.T) {
+	alloc := c.NewWithDebug()
+
+	v := NewUnmanagedSample(alloc)
+	count := SamplesCount/100 + 1
+	for idx := 0; idx < count; idx++ {
+		s := strconv.Itoa(rand.Intn(count))
+		v.AppendSliceOfStrings(s)
+		v.AppendPtrToSliceOfPtrToStrings(&s)
+		v.SetSliceOfSubsamplesCapacity(idx+1, true)
+		v.SliceOfSubsamples[idx].SetSomeString(s)
+	}
+	v.SetArrayOfStrings(0, "d")
+	v.SetArrayOfStrings(1, "b")
+	v.SetArrayOfStrings(2, "c")
+	v.SetArrayOfStrings(3, "a")
+
+	v.SortSliceOfStringsFunc(strings.Compare)
+	v.SortPtrToSliceOfPtrToStringsFunc(func(a, b *string) int {
+		return strings.Compare(*a, *b)
+	})
+	v.SortSliceOfSubsamplesFunc(func(a, b *UnmanagedSubSample) int {
+		return strings.Compare(a.SomeString, b.SomeString)
+	})
+	v.SortArrayOfStringsFunc(strings.Compare)
+
+	prev := ""
+	for idx, s := range v.SliceOfStrings {
+		if s < prev || *(*v.PtrToSliceOfPtrToStrings)[idx] != s || v.SliceOfSubsamples[idx].SomeString != s {
+			t.Fatalf("elements not sorted")
+		}
+		prev = s
+	}
+	if strings.Join(v.ArrayOfStrings[:], "") != "abcd" {
+		t.Fatalf("array not sorted")
+	}
+
+	target := v.SliceOfStrings[count/2]
+	idx, found := v.BinarySearchSliceOfStringsFunc(target, strings.Compare)
+	if !found || v.SliceOfStrings[idx] != target {
+		t.Fatalf("element not found")
+	}
+	_, found = v.BinarySearchSliceOfStringsFunc("x", strings.Compare)
+	if found {
+		t.Fatalf("missing element found")
+	}
+
+	v.Free()
+	if alloc.Usage() != 0 {
+		t.Fatalf("Usage is not zero! [%v]", alloc.Usage())
+	}
 }
 
 func makeSampleChange(v *UnmanagedSample) {
@@ -306,3 +884,8 @@ func getRandomPtrToString() *string {
 	s := strings.Repeat("*", 16+intVal)
 	return &s
 }
+
+func (a *freeCountingArena) Free(ptr unsafe.Pointer) {
+	a.frees++
+	a.ArenaAllocator.Free(ptr)
+}